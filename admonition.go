@@ -0,0 +1,196 @@
+package h2md
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// AdmonitionStyle how note, warning and tip boxes are written
+type AdmonitionStyle int
+
+const (
+	// AdmonitionGitHub GitHub alerts: > [!NOTE]
+	AdmonitionGitHub AdmonitionStyle = iota
+	// AdmonitionMkDocs MkDocs blocks: !!! note "Title"
+	AdmonitionMkDocs
+	// AdmonitionDocusaurus Docusaurus fences: :::note[Title]
+	AdmonitionDocusaurus
+	// AdmonitionPandoc Pandoc fenced divs: ::: note, with a nested title div
+	AdmonitionPandoc
+	// AdmonitionQuote plain blockquote led by the bold title or kind: > **Warning**
+	AdmonitionQuote
+)
+
+// admonitionContainers classes marking a callout, the kind is read from the other classes
+// or from the class suffix, e.g. markdown-alert-note
+var admonitionContainers = []string{
+	"admonition",
+	"markdown-alert",
+	"theme-admonition",
+	"confluence-information-macro",
+	"callout",
+}
+
+// admonitionKinds known kinds and their synonyms
+var admonitionKinds = map[string]string{
+	"note":        "note",
+	"info":        "info",
+	"information": "info",
+	"tip":         "tip",
+	"hint":        "tip",
+	"success":     "tip",
+	"check":       "tip",
+	"important":   "important",
+	"warning":     "warning",
+	"attention":   "warning",
+	"caution":     "caution",
+	"danger":      "danger",
+	"error":       "danger",
+	"failure":     "danger",
+	"bug":         "danger",
+	"abstract":    "note",
+	"summary":     "note",
+	"question":    "note",
+	"example":     "note",
+	"quote":       "note",
+	"seealso":     "note",
+	"todo":        "note",
+}
+
+// admonitionTitles classes marking the callout title
+var admonitionTitles = []string{
+	"admonition-title",
+	"markdown-alert-title",
+	"callout-title",
+	"title",
+}
+
+// SetAdmonitionStyle Set how note, warning and tip boxes are written
+func (h *Converter) SetAdmonitionStyle(style AdmonitionStyle) {
+	h.admonitionStyle = style
+}
+
+// SetAdmonitionClass Map an element class to an admonition kind (note, info, tip, important, warning, caution, danger)
+// or one of its synonyms, an empty or unknown kind is an error and leaves the mapping unchanged
+func (h *Converter) SetAdmonitionClass(class, kind string) error {
+	k, ok := admonitionKinds[strings.ToLower(kind)]
+	if !ok {
+		return fmt.Errorf("h2md: unknown admonition kind %q", kind)
+	}
+	if h.admonitionClasses == nil {
+		h.admonitionClasses = make(map[string]string)
+	}
+	h.admonitionClasses[class] = k
+	return nil
+}
+
+// kindTitle return the kind capitalised, the title of a callout without one
+func kindTitle(kind string) string {
+	if kind == "" {
+		return ""
+	}
+	return strings.ToUpper(kind[:1]) + kind[1:]
+}
+
+// admonitionKind return the kind of the callout element
+func (h *Converter) admonitionKind(n *html.Node) (string, bool) {
+	classes := strings.Fields(attr(n, "class"))
+	for _, class := range classes {
+		if kind, ok := h.admonitionClasses[class]; ok {
+			return kind, true
+		}
+	}
+	var container bool
+	for _, class := range classes {
+		for _, c := range admonitionContainers {
+			if class == c {
+				container = true
+			} else if strings.HasPrefix(class, c+"-") {
+				if kind, ok := admonitionKinds[strings.ToLower(class[len(c)+1:])]; ok {
+					return kind, true
+				}
+			}
+		}
+	}
+	if !container {
+		return "", false
+	}
+	for _, class := range classes {
+		if kind, ok := admonitionKinds[strings.ToLower(class)]; ok {
+			return kind, true
+		}
+	}
+	return "note", true
+}
+
+// admonitionTitle return the title element of the callout
+func admonitionTitle(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		for _, class := range strings.Fields(attr(c, "class")) {
+			for _, t := range admonitionTitles {
+				// Docusaurus uses css modules: admonitionHeading_Gvgb
+				if class == t || strings.HasPrefix(class, "admonitionHeading") {
+					return c
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// admonition return the callout in the chosen style
+func (h *Converter) admonition(kind, title, text string) string {
+	if strings.EqualFold(title, kind) {
+		title = ""
+	}
+	switch h.admonitionStyle {
+	case AdmonitionMkDocs:
+		s := "\n\n!!! " + kind
+		if title != "" {
+			s += " \"" + title + "\""
+		}
+		return s + "\n\n    " + indent(text, "    ") + "\n"
+	case AdmonitionDocusaurus:
+		switch kind {
+		case "important":
+			kind = "info"
+		case "caution":
+			kind = "warning"
+		}
+		s := "\n\n:::" + kind
+		if title != "" {
+			s += "[" + title + "]"
+		}
+		return s + "\n\n" + text + "\n\n:::\n"
+	case AdmonitionPandoc:
+		s := "\n\n::: " + kind + "\n"
+		if title != "" {
+			s += "::: title\n" + title + "\n:::\n\n"
+		}
+		return s + text + "\n:::\n"
+	case AdmonitionQuote:
+		if title == "" {
+			title = kindTitle(kind)
+		}
+		if title == "" {
+			return "\n\n" + quoteLines(text) + "\n"
+		}
+		return "\n\n" + quoteLines("**"+title+"**\n"+text) + "\n"
+	}
+	switch kind {
+	case "info":
+		kind = "note"
+	case "danger":
+		kind = "caution"
+	}
+	s := "> [!" + strings.ToUpper(kind) + "]"
+	if title != "" {
+		s += "\n> **" + title + "**"
+	}
+	return "\n\n" + s + "\n" + quoteLines(text) + "\n"
+}
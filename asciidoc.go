@@ -0,0 +1,274 @@
+package h2md
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AsciiDoc renderer writing AsciiDoc for Asciidoctor
+var AsciiDoc Renderer = asciiDoc{}
+
+// asciiDocBlockStart lines that would start a block or a list
+var asciiDocBlockStart = regexp.MustCompile(`^(=+ |[*.-]+ |\d+\. |//|\[|\|===|'''|-{4}|\.{4}|_{4}|\*{4}|={4}|\+$|(NOTE|TIP|IMPORTANT|WARNING|CAUTION): |\.[^ .])`)
+
+// asciiDoc renderer of AsciiDoc
+type asciiDoc struct{}
+
+// Escape escape the formatting marks that could open a span and attribute references
+func (asciiDoc) Escape(text string) string {
+	var b strings.Builder
+	rs := []rune(text)
+	at := func(i int) rune {
+		if i >= 0 && i < len(rs) {
+			return rs[i]
+		}
+		return 0
+	}
+	for i, r := range rs {
+		switch r {
+		case '*', '_', '`', '#', '^', '~':
+			if !isAlnum(at(i-1)) && at(i+1) != ' ' && at(i+1) != 0 {
+				b.WriteByte('\\')
+			}
+		case '{':
+			if strings.ContainsRune(string(rs[i:]), '}') {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Paragraph return the inline content as a paragraph, guarding the lines that would start a block
+func (asciiDoc) Paragraph(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Trim(line, " ")
+		if line == "" || line == "+" {
+			continue
+		}
+		if asciiDocBlockStart.MatchString(line) {
+			line = "{empty}" + line
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	lines[len(lines)-1] = strings.TrimSuffix(lines[len(lines)-1], " +")
+	return strings.Join(lines, "\n")
+}
+
+// Heading return the section title, h1 is a level 1 section as the document title is left to the caller
+func (asciiDoc) Heading(level int, text, id string, classes []string) string {
+	if text == "" {
+		return ""
+	}
+	if level > 5 {
+		level = 5
+	}
+	s := strings.Repeat("=", level+1) + " " + text
+	if id != "" {
+		s = "[[" + id + "]]\n" + s
+	}
+	return s
+}
+
+// ThematicBreak return the thematic break
+func (asciiDoc) ThematicBreak() string {
+	return "'''"
+}
+
+// LineBreak return the hard line break
+func (asciiDoc) LineBreak() string {
+	return " +\n"
+}
+
+// Emphasis return _text_
+func (asciiDoc) Emphasis(text string) string {
+	return emphasis("_", "__", text)
+}
+
+// Strong return *text*
+func (asciiDoc) Strong(text string) string {
+	return emphasis("*", "**", text)
+}
+
+// Strikethrough return the line-through role
+func (asciiDoc) Strikethrough(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	return "[.line-through]#" + text + "#"
+}
+
+// Code return the literal monospace code
+func (asciiDoc) Code(code string) string {
+	if code = strings.ReplaceAll(code, "\n", " "); code == "" {
+		return ""
+	}
+	return "`+" + code + "+`"
+}
+
+// CodeBlock return the listing block, math becomes a stem block
+func (asciiDoc) CodeBlock(code, lang string) string {
+	if lang == "math" {
+		return "[stem]\n++++\n" + code + "\n++++"
+	}
+	fence := "----"
+	for _, line := range strings.Split(code, "\n") {
+		if len(line) >= len(fence) && strings.Trim(line, "-") == "" {
+			fence = line + "-"
+		}
+	}
+	s := fence + "\n" + code + "\n" + fence
+	if lang != "" {
+		s = "[source," + lang + "]\n" + s
+	}
+	return s
+}
+
+// Quote return the quote block
+func (asciiDoc) Quote(text string) string {
+	return "____\n" + text + "\n____"
+}
+
+// List return the list, nesting is written with repeated markers and
+// the following blocks of an item are attached with list continuations
+func (asciiDoc) List(l List) string {
+	marker := strings.Repeat("*", l.Depth+1)
+	if l.Ordered {
+		marker = strings.Repeat(".", l.Depth+1)
+	}
+	var items []string
+	for _, item := range l.Items {
+		if len(item) == 0 {
+			items = append(items, marker+" {empty}")
+			continue
+		}
+		s := marker + " " + item[0]
+		for _, chunk := range item[1:] {
+			if isAsciiDocList(chunk, l.Depth+1) {
+				s += "\n" + chunk
+			} else {
+				s += "\n+\n" + chunk
+			}
+		}
+		items = append(items, s)
+	}
+	s := strings.Join(items, "\n")
+	if l.Ordered && l.Start != 1 {
+		s = "[start=" + strconv.Itoa(l.Start) + "]\n" + s
+	}
+	return s
+}
+
+// isAsciiDocList report whether the block is a list nested at the depth
+func isAsciiDocList(block string, depth int) bool {
+	if strings.HasPrefix(block, "[start=") {
+		return true
+	}
+	return strings.HasPrefix(block, strings.Repeat("*", depth+1)+" ") ||
+		strings.HasPrefix(block, strings.Repeat(".", depth+1)+" ")
+}
+
+// Link return the url macro, a bare url when the text is the url
+func (a asciiDoc) Link(text, href, title string) string {
+	href = strings.ReplaceAll(href, " ", "%20")
+	absolute := strings.Contains(href, "://") || strings.HasPrefix(href, "mailto:")
+	if absolute && (text == "" || text == a.Escape(href)) {
+		return href
+	}
+	if text == "" {
+		text = href
+	}
+	if !strings.HasPrefix(text, "image:") {
+		text = strings.ReplaceAll(text, "]", "\\]")
+	}
+	if absolute {
+		return href + "[" + text + "]"
+	}
+	return "link:" + href + "[" + text + "]"
+}
+
+// Image return the inline image macro
+func (asciiDoc) Image(alt, src, title string) string {
+	alt = strings.NewReplacer("]", "\\]", ",", " ", "\n", " ").Replace(alt)
+	s := "image:" + strings.ReplaceAll(src, " ", "%20") + "[" + alt
+	if title != "" {
+		s += `,title="` + strings.ReplaceAll(title, `"`, "'") + `"`
+	}
+	return s + "]"
+}
+
+// Table return the table with cells spanning and holding blocks
+func (asciiDoc) Table(t Table) string {
+	var cols []string
+	for _, td := range t.Rows[0] {
+		spec := "1"
+		switch td.Align {
+		case "left":
+			spec = "<"
+		case "center":
+			spec = "^"
+		case "right":
+			spec = ">"
+		}
+		for span := 0; span < td.Colspan; span++ {
+			cols = append(cols, spec)
+		}
+	}
+	if len(cols) == 0 {
+		return ""
+	}
+	attrs := `cols="` + strings.Join(cols, ",") + `"`
+	header := len(t.Rows) > 1
+	for _, td := range t.Rows[0] {
+		header = header && td.Header
+	}
+	if header {
+		attrs = "%header," + attrs
+	}
+	var s string
+	if t.Caption != "" {
+		s = "." + strings.ReplaceAll(t.Caption, "\n", " ") + "\n"
+	}
+	s += "[" + attrs + "]\n|==="
+	for _, tr := range t.Rows {
+		s += "\n"
+		for _, td := range tr {
+			var spec string
+			switch {
+			case td.Colspan > 1 && td.Rowspan > 1:
+				spec = strconv.Itoa(td.Colspan) + "." + strconv.Itoa(td.Rowspan) + "+"
+			case td.Colspan > 1:
+				spec = strconv.Itoa(td.Colspan) + "+"
+			case td.Rowspan > 1:
+				spec = "." + strconv.Itoa(td.Rowspan) + "+"
+			}
+			text := strings.ReplaceAll(td.Text, "|", "\\|")
+			if strings.Contains(text, "\n") {
+				spec += "a"
+			}
+			s += "\n" + spec + "|" + text
+		}
+	}
+	return s + "\n|==="
+}
+
+// Admonition return the admonition block
+func (asciiDoc) Admonition(kind, title, text string) string {
+	switch kind {
+	case "info":
+		kind = "note"
+	case "danger":
+		kind = "caution"
+	}
+	s := "[" + strings.ToUpper(kind) + "]\n"
+	if title != "" {
+		s += "." + title + "\n"
+	}
+	return s + "====\n" + text + "\n===="
+}
@@ -0,0 +1,218 @@
+package h2md
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// BatchManifest name of the file in the output directory holding the hashes of the converted sources
+const BatchManifest = ".h2md-manifest.json"
+
+// Batch status of a file
+const (
+	BatchConverted = "converted"
+	BatchSkipped   = "skipped"
+	BatchFailed    = "failed"
+)
+
+// Batch settings of a directory conversion
+type Batch struct {
+	// Workers number of files converted at once, 0 uses the number of CPUs
+	Workers int
+	// New return the converter of the html of a file, NewH2MD when nil
+	New func(src []byte) (*H2MD, error)
+	// Ext extension of the written files, ".md" when empty
+	Ext string
+	// Key settings the outputs depend on, changing it converts the unchanged files again
+	Key string
+	// Force convert the unchanged files too
+	Force bool
+	// Progress called after each file with the number of files done and the total,
+	// from one goroutine at a time
+	Progress func(done, total int, r BatchResult)
+}
+
+// BatchResult conversion result of a file
+type BatchResult struct {
+	// Source path of the html file relative to the source directory
+	Source string `json:"source"`
+	// Output path of the written file relative to the output directory
+	Output string `json:"output"`
+	// Status converted, skipped or failed
+	Status string `json:"status"`
+	// Error reason of the failure
+	Error string `json:"error,omitempty"`
+}
+
+// BatchSummary results of a directory conversion, the files are sorted by source
+type BatchSummary struct {
+	// Total number of html files
+	Total int `json:"total"`
+	// Converted, Skipped and Failed number of files of each status
+	Converted int `json:"converted"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+	// Files result of each file
+	Files []BatchResult `json:"files"`
+}
+
+// batchExts extensions of the converted files
+var batchExts = map[string]bool{".html": true, ".htm": true, ".xhtml": true}
+
+// ConvertDir convert the html files of the src tree into the same tree under dst,
+// the files whose content hash is unchanged since the last conversion are skipped.
+// The error is about the directories or the cancelled context, file failures are in the summary
+func ConvertDir(ctx context.Context, src, dst string, b Batch) (*BatchSummary, error) {
+	sources, err := htmlFiles(src)
+	if err != nil {
+		return nil, err
+	}
+	if b.New == nil {
+		b.New = func(src []byte) (*H2MD, error) { return NewH2MD(string(src)) }
+	}
+	if b.Ext == "" {
+		b.Ext = ".md"
+	}
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	manifest := readManifest(filepath.Join(dst, BatchManifest))
+
+	jobs := make(chan string)
+	type result struct {
+		BatchResult
+		hash string
+	}
+	// buffered so workers never block once the context is cancelled
+	results := make(chan result, len(sources))
+	for i := 0; i < workers; i++ {
+		go func() {
+			for rel := range jobs {
+				r := result{BatchResult: BatchResult{Source: rel, Output: strings.TrimSuffix(rel, filepath.Ext(rel)) + b.Ext}}
+				var err error
+				r.hash, r.Status, err = convertFile(filepath.Join(src, rel), filepath.Join(dst, r.Output), manifest[filepath.ToSlash(rel)], b)
+				if err != nil {
+					r.Error = err.Error()
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, rel := range sources {
+			select {
+			case jobs <- rel:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	summary := &BatchSummary{Total: len(sources), Files: make([]BatchResult, 0, len(sources))}
+	hashes := make(map[string]string)
+	for done := 1; done <= len(sources); done++ {
+		var r result
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		switch r.Status {
+		case BatchConverted:
+			summary.Converted++
+		case BatchSkipped:
+			summary.Skipped++
+		case BatchFailed:
+			summary.Failed++
+		}
+		if r.Status != BatchFailed {
+			hashes[filepath.ToSlash(r.Source)] = r.hash
+		}
+		summary.Files = append(summary.Files, r.BatchResult)
+		if b.Progress != nil {
+			b.Progress(done, len(sources), r.BatchResult)
+		}
+	}
+	sort.Slice(summary.Files, func(i, j int) bool { return summary.Files[i].Source < summary.Files[j].Source })
+	if err := writeManifest(filepath.Join(dst, BatchManifest), hashes); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// htmlFiles return the sorted paths of the html files under the directory, relative to it
+func htmlFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !batchExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+// convertFile convert the html file unless its hash is the previous one and the output exists,
+// return the hash of the source and the status
+func convertFile(src, dst, previous string, b Batch) (hash, status string, err error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", BatchFailed, err
+	}
+	sum := sha256.Sum256(append([]byte(b.Key+"\x00"), data...))
+	hash = hex.EncodeToString(sum[:])
+	if !b.Force && hash == previous {
+		if _, err := os.Stat(dst); err == nil {
+			return hash, BatchSkipped, nil
+		}
+	}
+	h, err := b.New(data)
+	if err != nil {
+		return hash, BatchFailed, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return hash, BatchFailed, err
+	}
+	if err := os.WriteFile(dst, []byte(h.Text()+"\n"), 0644); err != nil {
+		return hash, BatchFailed, err
+	}
+	return hash, BatchConverted, nil
+}
+
+// readManifest return the source hashes of the last conversion, empty when there is none
+func readManifest(path string) map[string]string {
+	hashes := make(map[string]string)
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &hashes)
+	}
+	return hashes
+}
+
+// writeManifest write the source hashes, sorted by path
+func writeManifest(path string, hashes map[string]string) error {
+	data, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,365 @@
+package h2md
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// chat renderer of a chat platform markup, the elements chats lack are degraded:
+// headings become bold lines, images links and tables monospace blocks
+type chat struct {
+	// escape escape the text
+	escape func(text string) string
+	// lineStart escape what would start a block at the line start, may be nil
+	lineStart func(line string) string
+	// bold, italic and strike open and close delimiters
+	bold, italic, strike [2]string
+	// code return the code span
+	code func(code string) string
+	// pre return the code block
+	pre func(code, lang string) string
+	// link return the link of the rendered text, text is "" when it is the url
+	link func(text, href string) string
+	// quote return the quoted blocks
+	quote func(text string) string
+	// heading return the heading, nil writes a bold line
+	heading func(level int, text string) string
+	// bullets list markers by depth
+	bullets []string
+	// number return the marker of the numbered item
+	number func(n int) string
+}
+
+// Slack renderer writing Slack mrkdwn
+var Slack Renderer = &chat{
+	escape:  slackEscape,
+	bold:    [2]string{"*", "*"},
+	italic:  [2]string{"_", "_"},
+	strike:  [2]string{"~", "~"},
+	code:    func(code string) string { return "`" + slackEscape(code) + "`" },
+	pre:     func(code, lang string) string { return "```\n" + slackEscape(code) + "\n```" },
+	quote:   quoteLines,
+	bullets: []string{"•", "◦", "▪"},
+	number:  func(n int) string { return strconv.Itoa(n) + "." },
+	link: func(text, href string) string {
+		if text == "" {
+			return "<" + slackEscape(href) + ">"
+		}
+		return "<" + slackEscape(href) + "|" + strings.ReplaceAll(text, "|", "¦") + ">"
+	},
+}
+
+// TelegramMarkdownV2 renderer writing Telegram MarkdownV2
+var TelegramMarkdownV2 Renderer = &chat{
+	escape:  telegramEscape,
+	bold:    [2]string{"*", "*"},
+	italic:  [2]string{"_", "_"},
+	strike:  [2]string{"~", "~"},
+	code:    func(code string) string { return "`" + telegramCodeEscape(code) + "`" },
+	pre:     func(code, lang string) string { return "```" + lang + "\n" + telegramCodeEscape(code) + "\n```" },
+	quote:   quoteLines,
+	bullets: []string{"•", "◦", "▪"},
+	number:  func(n int) string { return strconv.Itoa(n) + "\\." },
+	link: func(text, href string) string {
+		href = strings.NewReplacer("\\", "\\\\", ")", "\\)").Replace(href)
+		if text == "" {
+			text = telegramEscape(href)
+		}
+		return "[" + text + "](" + href + ")"
+	},
+}
+
+// TelegramHTML renderer writing the html subset of Telegram
+var TelegramHTML Renderer = &chat{
+	escape:  html.EscapeString,
+	bold:    [2]string{"<b>", "</b>"},
+	italic:  [2]string{"<i>", "</i>"},
+	strike:  [2]string{"<s>", "</s>"},
+	code:    func(code string) string { return "<code>" + html.EscapeString(code) + "</code>" },
+	quote:   func(text string) string { return "<blockquote>" + text + "</blockquote>" },
+	bullets: []string{"•", "◦", "▪"},
+	number:  func(n int) string { return strconv.Itoa(n) + "." },
+	pre: func(code, lang string) string {
+		if lang == "" {
+			return "<pre>" + html.EscapeString(code) + "</pre>"
+		}
+		return `<pre><code class="language-` + html.EscapeString(lang) + `">` + html.EscapeString(code) + "</code></pre>"
+	},
+	link: func(text, href string) string {
+		if text == "" {
+			text = html.EscapeString(href)
+		}
+		return `<a href="` + html.EscapeString(href) + `">` + text + "</a>"
+	},
+}
+
+// Discord renderer writing Discord markdown
+var Discord Renderer = &chat{
+	escape:    discordEscape,
+	lineStart: escapeLineStart,
+	bold:      [2]string{"**", "**"},
+	italic:    [2]string{"*", "*"},
+	strike:    [2]string{"~~", "~~"},
+	code:      codeSpan,
+	quote:     quoteLines,
+	bullets:   []string{"-"},
+	number:    func(n int) string { return strconv.Itoa(n) + "." },
+	pre: func(code, lang string) string {
+		return "```" + lang + "\n" + strings.ReplaceAll(code, "```", "`\u200b``") + "\n```"
+	},
+	link: func(text, href string) string {
+		if text == "" {
+			return href
+		}
+		return "[" + text + "](" + destination(href) + ")"
+	},
+	heading: func(level int, text string) string {
+		if level > 3 {
+			return "**" + text + "**"
+		}
+		return strings.Repeat("#", level) + " " + text
+	},
+}
+
+// slackEscape escape the control characters of mrkdwn, its formatting marks have no escape
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// telegramEscape escape every character MarkdownV2 reserves
+func telegramEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// telegramCodeEscape escape the characters MarkdownV2 reserves inside code
+func telegramCodeEscape(code string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(code)
+}
+
+// discordEscape escape the formatting marks of Discord markdown
+func discordEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\*_~`|[]<", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// quoteLines prefix the lines with >
+func quoteLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrap wrap the text in the delimiters, moving the surrounding spaces out
+func wrap(delims [2]string, text string) string {
+	trimmed := strings.Trim(text, " ")
+	if trimmed == "" {
+		return text
+	}
+	var s string
+	if strings.HasPrefix(text, " ") {
+		s = " "
+	}
+	s += delims[0] + trimmed + delims[1]
+	if strings.HasSuffix(text, " ") {
+		s += " "
+	}
+	return s
+}
+
+// Escape escape the text
+func (c *chat) Escape(text string) string {
+	return c.escape(text)
+}
+
+// Paragraph return the trimmed lines
+func (c *chat) Paragraph(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Trim(line, " "); line == "" {
+			continue
+		}
+		if c.lineStart != nil {
+			line = c.lineStart(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Heading return the heading, or a bold line
+func (c *chat) Heading(level int, text, id string, classes []string) string {
+	if text == "" {
+		return ""
+	}
+	if c.heading != nil {
+		return c.heading(level, text)
+	}
+	return wrap(c.bold, text)
+}
+
+// ThematicBreak return a line of dashes
+func (c *chat) ThematicBreak() string {
+	return "———"
+}
+
+// LineBreak return the line break
+func (c *chat) LineBreak() string {
+	return "\n"
+}
+
+// Emphasis return the italic text
+func (c *chat) Emphasis(text string) string {
+	return wrap(c.italic, text)
+}
+
+// Strong return the bold text
+func (c *chat) Strong(text string) string {
+	return wrap(c.bold, text)
+}
+
+// Strikethrough return the struck text
+func (c *chat) Strikethrough(text string) string {
+	return wrap(c.strike, text)
+}
+
+// Code return the code span
+func (c *chat) Code(code string) string {
+	if code = strings.ReplaceAll(code, "\n", " "); code == "" {
+		return ""
+	}
+	return c.code(code)
+}
+
+// CodeBlock return the code block
+func (c *chat) CodeBlock(code, lang string) string {
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	return c.pre(code, lang)
+}
+
+// Quote return the quoted blocks
+func (c *chat) Quote(text string) string {
+	if text == "" {
+		return ""
+	}
+	return c.quote(text)
+}
+
+// List return the items after bullets or numbers, nested lists are indented
+func (c *chat) List(l List) string {
+	var items []string
+	for i, item := range l.Items {
+		marker := c.bullets[l.Depth%len(c.bullets)]
+		if l.Ordered {
+			marker = c.number(l.Start + i)
+		}
+		content := strings.Join(item, "\n")
+		pad := strings.Repeat(" ", len([]rune(marker))+1)
+		items = append(items, strings.TrimRight(marker+" "+indent(content, pad), " "))
+	}
+	return strings.Join(items, "\n")
+}
+
+// Link return the link, a bare url when the text is the url
+func (c *chat) Link(text, href, title string) string {
+	if text == c.escape(href) {
+		text = ""
+	}
+	return c.link(text, href)
+}
+
+// Image return a link to the image
+func (c *chat) Image(alt, src, title string) string {
+	if alt = strings.TrimSpace(alt); alt != "" {
+		return c.link(c.escape(alt), src)
+	}
+	return c.link("", src)
+}
+
+// Table return the table as an aligned monospace block
+func (c *chat) Table(t Table) string {
+	table := textTable(t.Node)
+	if table == "" {
+		return ""
+	}
+	if t.Caption != "" {
+		return t.Caption + "\n" + c.pre(table, "")
+	}
+	return c.pre(table, "")
+}
+
+// Admonition return the quoted box led by its kind or title in bold
+func (c *chat) Admonition(kind, title, text string) string {
+	if title == "" {
+		title = c.escape(kindTitle(kind))
+	}
+	if title == "" {
+		return c.quote(text)
+	}
+	return c.quote(wrap(c.bold, title) + "\n" + text)
+}
+
+// textTable return the plain text of the table with padded columns,
+// the header row is underlined
+func textTable(table *html.Node) string {
+	var rows [][]string
+	var widths []int
+	var header bool
+	for i, tr := range tableRows(table) {
+		var row []string
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.Type != html.ElementNode || td.Data != "td" && td.Data != "th" {
+				continue
+			}
+			if i == 0 && td.Data == "th" {
+				header = true
+			}
+			text := strings.TrimSpace(whitespace.ReplaceAllString(textContent(td), " "))
+			if len(widths) <= len(row) {
+				widths = append(widths, 0)
+			}
+			if w := displayWidth(text); w > widths[len(row)] {
+				widths[len(row)] = w
+			}
+			row = append(row, text)
+		}
+		rows = append(rows, row)
+	}
+	if len(widths) == 0 {
+		return ""
+	}
+	var lines []string
+	for i, row := range rows {
+		var cells []string
+		for j, text := range row {
+			cells = append(cells, text+strings.Repeat(" ", widths[j]-displayWidth(text)))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, " | "), " "))
+		if i == 0 && header {
+			var rule []string
+			for _, w := range widths {
+				rule = append(rule, strings.Repeat("-", w))
+			}
+			lines = append(lines, strings.Join(rule, "-+-"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
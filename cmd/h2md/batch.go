@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"github.com/diiyw/h2md"
+)
+
+// runBatch convert a directory tree into the output directory with a pool of workers,
+// the files unchanged since the last run are skipped
+func runBatch(args []string, stdout, stderr io.Writer) int {
+	var workers int
+	var summaryPath string
+	var progress, force bool
+	o, fs, code := parseFlags(args, stderr, "batch [flags] -o dir dir", func(fs *flag.FlagSet) {
+		fs.IntVar(&workers, "j", runtime.NumCPU(), "number of files converted at once")
+		fs.StringVar(&summaryPath, "summary", "", "write the json summary to the `file`, - for stdout")
+		fs.BoolVar(&progress, "progress", false, "report every file on stderr")
+		fs.BoolVar(&force, "force", false, "convert the unchanged files too")
+	})
+	if fs == nil {
+		return code
+	}
+	if fs.NArg() != 1 || o.Output == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	b := newBatch(o)
+	b.Workers = workers
+	b.Force = force
+	if progress {
+		b.Progress = func(done, total int, r h2md.BatchResult) {
+			fmt.Fprintf(stderr, "[%d/%d] %s %s\n", done, total, r.Status, r.Source)
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	summary, err := convertDir(ctx, fs.Arg(0), o.Output, b, stderr)
+	if summary == nil {
+		return exitFailure
+	}
+	if summaryPath != "" {
+		if err := writeSummary(summaryPath, summary, stdout); err != nil {
+			fmt.Fprintln(stderr, "h2md:", err)
+			return exitFailure
+		}
+	}
+	if err != nil || summary.Failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// newBatch return the batch converting with the options
+func newBatch(o *options) h2md.Batch {
+	// the outputs depend on every option, changing one converts all files again
+	key, _ := json.Marshal(o)
+	return h2md.Batch{
+		New: func(src []byte) (*h2md.H2MD, error) { return o.newH2MD(string(src)) },
+		Ext: o.ext(),
+		Key: string(key),
+	}
+}
+
+// convertDir convert the directory tree and report the errors and failed files on stderr
+func convertDir(ctx context.Context, src, dst string, b h2md.Batch, stderr io.Writer) (*h2md.BatchSummary, error) {
+	summary, err := h2md.ConvertDir(ctx, src, dst, b)
+	if err != nil {
+		fmt.Fprintln(stderr, "h2md:", err)
+	}
+	if summary != nil {
+		for _, r := range summary.Files {
+			if r.Status == h2md.BatchFailed {
+				fmt.Fprintf(stderr, "h2md: %s: %s\n", r.Source, r.Error)
+			}
+		}
+	}
+	return summary, err
+}
+
+// writeSummary write the indented json summary to the file or stdout
+func writeSummary(path string, summary *h2md.BatchSummary, stdout io.Writer) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,225 @@
+// Command h2md converts html files, directories or stdin to markdown and the other formats of h2md.
+//
+//	h2md page.html
+//	h2md -flavor gfm -o docs site/ 'blog/*.html'
+//	curl -s https://example.com | h2md -base-url https://example.com
+//	h2md batch -j 8 -summary summary.json -o docs site
+//	h2md watch -flavor gfm -o docs site
+//	h2md serve -addr :8080 -concurrency 4
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diiyw/h2md"
+)
+
+// exit codes
+const (
+	exitOK = iota
+	// exitFailure a file could not be read, converted or written
+	exitFailure
+	// exitUsage invalid flags, options or arguments
+	exitUsage
+)
+
+// htmlExts extensions of the files converted in directories
+var htmlExts = map[string]bool{".html": true, ".htm": true, ".xhtml": true}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run the command with the arguments and return the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "batch":
+			return runBatch(args[1:], stdout, stderr)
+		case "watch":
+			return runWatch(args[1:], stderr)
+		case "serve":
+			return runServe(args[1:], stderr)
+		}
+	}
+	o, fs, code := parseFlags(args, stderr, "[flags] [file | dir | glob ...]\nhtml is read from stdin without arguments or with -", nil)
+	if fs == nil {
+		return code
+	}
+	files, err := inputs(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "h2md:", err)
+		return exitFailure
+	}
+	for _, in := range files {
+		if err := convertFile(o, in, stdin, stdout); err != nil {
+			fmt.Fprintln(stderr, "h2md:", err)
+			code = exitFailure
+		}
+	}
+	return code
+}
+
+// parseFlags return the options of the config file and the flags, the flag set is nil
+// with the exit code when the command should stop. more defines the flags of the subcommand
+func parseFlags(args []string, stderr io.Writer, usage string, more func(fs *flag.FlagSet)) (*options, *flag.FlagSet, int) {
+	o := defaultOptions()
+	if path := configPath(args); path != "" {
+		if err := loadConfig(path, &o); err != nil {
+			fmt.Fprintln(stderr, "h2md:", err)
+			return nil, nil, exitUsage
+		}
+	}
+	fs := flag.NewFlagSet("h2md", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: h2md "+usage)
+		fs.PrintDefaults()
+	}
+	bindFlags(fs, &o)
+	if more != nil {
+		more(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, nil, exitOK
+		}
+		return nil, nil, exitUsage
+	}
+	if err := o.check(); err != nil {
+		fmt.Fprintln(stderr, "h2md:", err)
+		return nil, nil, exitUsage
+	}
+	return &o, fs, exitOK
+}
+
+// input a file or url to convert and its path under the output directory
+type input struct {
+	// url page fetched instead of the file, written to stdout
+	url string
+	// path file path, "" reads stdin
+	path string
+	// rel path of the output relative to the output directory
+	rel string
+}
+
+// inputs return the files and urls of the arguments, directories are walked for html files
+// and globs expanded, no arguments read stdin
+func inputs(args []string) ([]input, error) {
+	if len(args) == 0 {
+		return []input{{}}, nil
+	}
+	var files []input
+	for _, arg := range args {
+		if arg == "-" {
+			files = append(files, input{})
+			continue
+		}
+		if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+			files = append(files, input{url: arg})
+			continue
+		}
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no matching files", arg)
+			}
+			paths = matches
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				files = append(files, input{path: path, rel: relPath(path)})
+				continue
+			}
+			dir := path
+			err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() || !htmlExts[strings.ToLower(filepath.Ext(path))] {
+					return nil
+				}
+				rel, err := filepath.Rel(dir, path)
+				if err != nil {
+					return err
+				}
+				files = append(files, input{path: path, rel: rel})
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// relPath return the path of a file argument under the output directory,
+// the base name when it is outside the working directory
+func relPath(path string) string {
+	path = filepath.Clean(path)
+	if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return filepath.Base(path)
+	}
+	return path
+}
+
+// outputPath return the path of the converted file in the output directory
+func outputPath(o *options, rel string) string {
+	return filepath.Join(o.Output, strings.TrimSuffix(rel, filepath.Ext(rel))+o.ext())
+}
+
+// convertFile convert the input and write it to the output directory or stdout
+func convertFile(o *options, in input, stdin io.Reader, stdout io.Writer) error {
+	if in.url != "" {
+		h, err := h2md.NewH2MDFromURL(context.Background(), in.url, &h2md.URLOptions{Charset: o.Charset})
+		if err != nil {
+			return err
+		}
+		// the page url is the base url unless one is set
+		o.apply(h)
+		_, err = io.WriteString(stdout, h.Text()+"\n")
+		return err
+	}
+	name := in.path
+	var src []byte
+	var err error
+	if in.path == "" {
+		name = "stdin"
+		src, err = io.ReadAll(stdin)
+	} else {
+		src, err = os.ReadFile(in.path)
+	}
+	if err != nil {
+		return err
+	}
+	h, err := o.newH2MD(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	text := h.Text() + "\n"
+	if o.Output == "" || in.path == "" {
+		_, err = io.WriteString(stdout, text)
+		return err
+	}
+	out := outputPath(o, in.rel)
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(out, []byte(text), 0644)
+}
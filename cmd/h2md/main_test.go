@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/h2md"
+)
+
+func TestRunStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-flavor", "gfm", "-base-url", "https://a.com/docs/"}, strings.NewReader(`<h1>Title</h1><p><a href="page">link</a></p>`), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expect exit %d but got %d: %s", exitOK, code, stderr.String())
+	}
+	expect := "# Title\n\n[link](https://a.com/docs/page)\n"
+	if stdout.String() != expect {
+		t.Errorf("Expect \"%s\" but got \"%s\"", expect, stdout.String())
+	}
+
+	// <p>中文</p> in Big5, too short to be told from GBK without the charset
+	stdout.Reset()
+	if code := run([]string{"-charset", "big5"}, strings.NewReader("<p>\xa4\xa4\xa4\xe5</p>"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expect exit %d but got %d: %s", exitOK, code, stderr.String())
+	}
+	if stdout.String() != "中文\n" {
+		t.Errorf("Expect \"中文\n\" but got \"%s\"", stdout.String())
+	}
+}
+
+func TestRunFlavorStyles(t *testing.T) {
+	page := `<dl><dt>Go</dt><dd>Language</dd></dl><p><img src="a.png" width="300"></p><div class="admonition warning"><p>Hot</p></div>`
+	tests := []struct {
+		args   []string
+		expect string
+	}{
+		{[]string{"-flavor", "gfm"}, "<dl>\n<dt>Go</dt>\n<dd>\n\nLanguage\n\n</dd>\n</dl>\n\n<img src=\"a.png\" alt=\"\" width=\"300\">\n\n> [!WARNING]\n> Hot\n"},
+		{[]string{"-flavor", "pandoc"}, "Go\n: Language\n\n![](a.png){width=300}\n\n::: warning\nHot\n:::\n"},
+		{[]string{"-flavor", "pandoc", "-admonition", "github", "-image-attrs", "drop"}, "Go\n: Language\n\n![](a.png)\n\n> [!WARNING]\n> Hot\n"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, strings.NewReader(page), &stdout, &stderr); code != exitOK {
+			t.Fatalf("Expect exit %d but got %d: %s", exitOK, code, stderr.String())
+		}
+		if stdout.String() != test.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", test.expect, stdout.String())
+		}
+	}
+}
+
+func TestRunOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	site := filepath.Join(dir, "site")
+	if err := os.MkdirAll(filepath.Join(site, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"index.html":     "<p><b>index</b></p>",
+		"sub/page.htm":   "<ul><li>item</li></ul>",
+		"sub/notes.txt":  "not html",
+		"sub/other.html": "<h2>Other</h2>",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(site, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := filepath.Join(dir, "h2md.json")
+	if err := os.WriteFile(config, []byte(`{"flavor": "gfm", "format": "rst"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-config", config, "-format", "markdown", "-o", out, site}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expect exit %d but got %d: %s", exitOK, code, stderr.String())
+	}
+	for name, expect := range map[string]string{
+		"index.md":     "**index**\n",
+		"sub/page.md":  "- item\n",
+		"sub/other.md": "## Other\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(data) != expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", expect, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "sub", "notes.md")); err == nil {
+		t.Error("Expect non html files to be skipped")
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(config, []byte(`{"admonition_classes": {"box": ""}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-flavor", "markdown"}, exitUsage},
+		{[]string{"-unknown"}, exitUsage},
+		{[]string{"-config", "missing.json"}, exitUsage},
+		{[]string{"-charset", "nope"}, exitUsage},
+		{[]string{"-flavor", "commonmark", "-admonition-class", "box="}, exitUsage},
+		{[]string{"-admonition-class", "box=nope"}, exitUsage},
+		{[]string{"-config", config}, exitUsage},
+		{[]string{"missing.html"}, exitFailure},
+		{[]string{"missing/*.html"}, exitFailure},
+		{[]string{"-h"}, exitOK},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, strings.NewReader(""), &stdout, &stderr); code != test.code {
+			t.Errorf("Expect exit %d for %v but got %d", test.code, test.args, code)
+		}
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	dir := t.TempDir()
+	site := filepath.Join(dir, "site")
+	if err := os.MkdirAll(site, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"a.html": "<h1>A</h1>", "b.html": "<p><i>b</i></p>"} {
+		if err := os.WriteFile(filepath.Join(site, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"batch", "-j", "2", "-flavor", "gfm", "-summary", "-", "-o", out, site}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expect exit %d but got %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"converted": 2`) {
+		t.Errorf("Expect 2 converted files in the summary but got \"%s\"", stdout.String())
+	}
+	stdout.Reset()
+	run([]string{"batch", "-flavor", "gfm", "-summary", "-", "-o", out, site}, nil, &stdout, &stderr)
+	if !strings.Contains(stdout.String(), `"skipped": 2`) {
+		t.Errorf("Expect 2 skipped files in the summary but got \"%s\"", stdout.String())
+	}
+	stdout.Reset()
+	run([]string{"batch", "-flavor", "commonmark", "-summary", "-", "-o", out, site}, nil, &stdout, &stderr)
+	if !strings.Contains(stdout.String(), `"converted": 2`) {
+		t.Errorf("Expect new options to convert the files again but got \"%s\"", stdout.String())
+	}
+	if code := run([]string{"batch", site}, nil, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expect exit %d without an output directory but got %d", exitUsage, code)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	site := filepath.Join(dir, "site")
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(filepath.Join(site, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(site, "a.html"), []byte("<h1>A</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() {
+		var stderr bytes.Buffer
+		done <- watch(ctx, []string{"-flavor", "gfm", "-debounce", "10ms", "-o", out, site}, &stderr)
+	}()
+	// wait polls for the content of the output, "" waits for its removal
+	wait := func(name, expect string) {
+		t.Helper()
+		var text string
+		for i := 0; i < 100; i++ {
+			data, err := os.ReadFile(filepath.Join(out, name))
+			if text = string(data); text == expect && (err == nil) == (expect != "") {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("Expect \"%s\" in %s but got \"%s\"", expect, name, text)
+	}
+	wait("a.md", "# A\n")
+	// the initial conversion is done before the watch starts
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(site, "sub", "b.html"), []byte("<p><b>b</b></p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wait(filepath.Join("sub", "b.md"), "**b**\n")
+	if err := os.WriteFile(filepath.Join(site, "a.html"), []byte("<h1>A2</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wait("a.md", "# A2\n")
+	if err := os.Remove(filepath.Join(site, "a.html")); err != nil {
+		t.Fatal(err)
+	}
+	wait("a.md", "")
+	cancel()
+	if code := <-done; code != exitOK {
+		t.Errorf("Expect exit %d but got %d", exitOK, code)
+	}
+}
+
+func TestServe(t *testing.T) {
+	o := defaultOptions()
+	o.Flavor = "gfm"
+	s := &server{options: &o, maxBytes: 512, timeout: time.Second, concurrency: 2}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	post := func(path, contentType, body string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+path, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+	tests := []struct {
+		path, contentType, body string
+		code                    int
+		expect                  string
+	}{
+		{"/convert", "text/html", "<h1>Title</h1><p><del>x</del></p>", http.StatusOK, "# Title\n\n~~x~~"},
+		{"/convert?format=rst", "text/html", "<p><b>x</b></p>", http.StatusOK, "**x**"},
+		{"/convert?flavor=commonmark&output=json", "text/html", "<title>T</title><p>x</p>", http.StatusOK, `{"markdown":"x","title":"T","format":"markdown","flavor":"commonmark",`},
+		{"/convert", "application/json", `{"html": "<p><i>x</i></p>", "options": {"format": "jira"}}`, http.StatusOK, "_x_"},
+		{"/convert?config=/etc/passwd", "text/html", "<p>x</p>", http.StatusBadRequest, `unknown option "config"`},
+		{"/convert?flavor=pandoc", "text/html", "<div class=\"admonition note\"><p>x</p></div>", http.StatusOK, "::: note\nx\n:::"},
+		{"/convert?flavor=nope", "text/html", "<p>x</p>", http.StatusBadRequest, `invalid -flavor "nope"`},
+		{"/convert", "text/html", strings.Repeat("<p>x</p>", 100), http.StatusRequestEntityTooLarge, "request body too large"},
+	}
+	for _, test := range tests {
+		code, body := post(test.path, test.contentType, test.body)
+		if code != test.code || !strings.HasPrefix(body, test.expect) {
+			t.Errorf("Expect %d \"%s\" but got %d \"%s\"", test.code, test.expect, code, body)
+		}
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("file", "page.html")
+	io.WriteString(fw, "<ul><li>a</li></ul>")
+	mw.Close()
+	if code, body := post("/convert", mw.FormDataContentType(), form.String()); code != http.StatusOK || body != "- a" {
+		t.Errorf("Expect 200 \"- a\" but got %d \"%s\"", code, body)
+	}
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expect a healthy service but got %v %v", resp, err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, line := range []string{
+		`h2md_http_requests_total{path="/convert",code="200"} 6`,
+		`h2md_http_requests_total{path="/convert",code="400"} 2`,
+		"h2md_conversion_seconds_count 6",
+		"h2md_conversions_in_flight 0",
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Expect \"%s\" in the metrics but got \"%s\"", line, data)
+		}
+	}
+}
+
+// panicRenderer renderer panicking on its first call
+type panicRenderer struct {
+	h2md.Renderer
+}
+
+func TestServePanic(t *testing.T) {
+	formats["panic"] = panicRenderer{}
+	defer delete(formats, "panic")
+	o := defaultOptions()
+	s := &server{options: &o, maxBytes: 512, timeout: time.Second, concurrency: 1}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	// the second request needs the slot the panicking conversion held
+	for _, test := range []struct {
+		path   string
+		code   int
+		expect string
+	}{
+		{"/convert?format=panic", http.StatusInternalServerError, "conversion failed\n"},
+		{"/convert", http.StatusOK, "x"},
+	} {
+		resp, err := http.Post(ts.URL+test.path, "text/html", strings.NewReader("<p>x</p>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.code || string(data) != test.expect {
+			t.Errorf("Expect %d \"%s\" but got %d \"%s\"", test.code, test.expect, resp.StatusCode, data)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/diiyw/h2md"
+	"golang.org/x/net/html/charset"
+)
+
+// options converter and output settings, set by the config file then the flags
+type options struct {
+	// Config json file holding the options, the flags override it
+	Config string `json:"-"`
+	// Output directory mirroring the input tree, "" writes to stdout
+	Output string `json:"output"`
+	// Ext extension of the written files, "" picks the one of the format
+	Ext string `json:"ext"`
+	// Format markdown or the renderer name
+	Format string `json:"format"`
+	// Flavor markdown flavor: gfm, commonmark, pandoc or "" for the default output
+	Flavor string `json:"flavor"`
+	// Confluence read the input as Confluence storage format
+	Confluence bool `json:"confluence"`
+	// BaseURL resolve relative links and images against it
+	BaseURL string `json:"base_url"`
+	// Charset encoding of the input, detected when empty
+	Charset string `json:"charset"`
+	// Admonition style of note and warning boxes: github, mkdocs, docusaurus, pandoc, quote,
+	// "" keeps the style of the flavor, the same for the other styles
+	Admonition string `json:"admonition"`
+	// AdmonitionClasses element classes mapped to admonition kinds
+	AdmonitionClasses map[string]string `json:"admonition_classes"`
+	// DefinitionList style of definition lists: extra, bold, html
+	DefinitionList string `json:"definition_list"`
+	// Details style of details elements: html, admonition
+	Details string `json:"details"`
+	// Figure style of figure captions: caption, alt
+	Figure string `json:"figure"`
+	// ImageAttrs style of image sizes: drop, html, braces
+	ImageAttrs string `json:"image_attrs"`
+	// ImageMaxWidth largest srcset candidate width to pick, 0 picks the largest
+	ImageMaxWidth int `json:"image_max_width"`
+	// EmbedHTML keep the raw html of embeds no provider handles
+	EmbedHTML bool `json:"embed_html"`
+}
+
+var (
+	// flavors values of the flavor option, "" keeps the default output
+	flavors = map[string]*h2md.Flavor{"gfm": &h2md.GFM, "commonmark": &h2md.CommonMark, "pandoc": &h2md.Pandoc}
+	// formats values of the format option, markdown is written by the flavor
+	formats = map[string]h2md.Renderer{
+		"markdown": nil, "asciidoc": h2md.AsciiDoc, "rst": h2md.RST, "jira": h2md.Jira, "slack": h2md.Slack,
+		"telegram": h2md.TelegramMarkdownV2, "telegram-html": h2md.TelegramHTML, "discord": h2md.Discord,
+	}
+	// formatExts file extensions of the formats
+	formatExts = map[string]string{
+		"markdown": ".md", "asciidoc": ".adoc", "rst": ".rst", "jira": ".txt", "slack": ".txt",
+		"telegram": ".txt", "telegram-html": ".html", "discord": ".md",
+	}
+)
+
+// style names indexed by the value of the style constants
+var (
+	admonitionStyles     = []string{"github", "mkdocs", "docusaurus", "pandoc", "quote"}
+	definitionListStyles = []string{"extra", "bold", "html"}
+	detailsStyles        = []string{"html", "admonition"}
+	figureStyles         = []string{"caption", "alt"}
+	imageAttrStyles      = []string{"drop", "html", "braces"}
+)
+
+// defaultOptions return the options of the library defaults, the styles are left
+// to the flavor
+func defaultOptions() options {
+	return options{Format: "markdown"}
+}
+
+// loadConfig read the json config file into the options
+func loadConfig(path string, o *options) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, o); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// configPath return the value of the config flag in the arguments
+func configPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if len(name) == len(arg) || len(arg)-len(name) > 2 {
+			continue
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "config=") {
+			return name[len("config="):]
+		}
+	}
+	return ""
+}
+
+// classMap flag value of class=kind pairs
+type classMap map[string]string
+
+func (m classMap) String() string {
+	var pairs []string
+	for class, kind := range m {
+		pairs = append(pairs, class+"="+kind)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m classMap) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return fmt.Errorf("%q is not class=kind", pair)
+		}
+		if err := h2md.NewConverter().SetAdmonitionClass(pair[:i], pair[i+1:]); err != nil {
+			return err
+		}
+		m[pair[:i]] = pair[i+1:]
+	}
+	return nil
+}
+
+// bindFlags define the flags of the options on the flag set, their defaults are the current values
+func bindFlags(fs *flag.FlagSet, o *options) {
+	if o.AdmonitionClasses == nil {
+		o.AdmonitionClasses = make(map[string]string)
+	}
+	fs.StringVar(&o.Config, "config", o.Config, "json `file` of options, flags override it")
+	fs.StringVar(&o.Output, "o", o.Output, "output `dir` mirroring the input tree, stdout when empty")
+	fs.StringVar(&o.Ext, "ext", o.Ext, "extension of the written files, by default the one of the format")
+	fs.StringVar(&o.Format, "format", o.Format, "output format: markdown, asciidoc, rst, jira, slack, telegram, telegram-html, discord")
+	fs.StringVar(&o.Flavor, "flavor", o.Flavor, "markdown flavor: gfm, commonmark, pandoc, the default output when empty")
+	fs.BoolVar(&o.Confluence, "confluence", o.Confluence, "read the input as Confluence storage format")
+	fs.StringVar(&o.BaseURL, "base-url", o.BaseURL, "resolve relative links and images against the `url`")
+	fs.StringVar(&o.Charset, "charset", o.Charset, "encoding of the input, e.g. gbk or big5, detected when empty")
+	fs.StringVar(&o.Admonition, "admonition", o.Admonition, "admonition style: "+strings.Join(admonitionStyles, ", ")+", the one of the flavor when empty")
+	fs.Var(classMap(o.AdmonitionClasses), "admonition-class", "map element classes to admonition kinds: `class=kind`,...")
+	fs.StringVar(&o.DefinitionList, "dl", o.DefinitionList, "definition list style: "+strings.Join(definitionListStyles, ", ")+", the one of the flavor when empty")
+	fs.StringVar(&o.Details, "details", o.Details, "details style: "+strings.Join(detailsStyles, ", ")+", the one of the flavor when empty")
+	fs.StringVar(&o.Figure, "figure", o.Figure, "figure caption style: "+strings.Join(figureStyles, ", ")+", the one of the flavor when empty")
+	fs.StringVar(&o.ImageAttrs, "image-attrs", o.ImageAttrs, "image size style: "+strings.Join(imageAttrStyles, ", ")+", the one of the flavor when empty")
+	fs.IntVar(&o.ImageMaxWidth, "image-max-width", o.ImageMaxWidth, "largest srcset candidate `width` to pick, 0 picks the largest")
+	fs.BoolVar(&o.EmbedHTML, "embed-html", o.EmbedHTML, "keep the raw html of unknown embeds")
+}
+
+// style return the index of the style name, -1 when it is unknown or empty
+func style(styles []string, name string) int {
+	for i, s := range styles {
+		if s == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// check report the first option holding an unknown value
+func (o *options) check() error {
+	_, format := formats[o.Format]
+	_, flavor := flavors[o.Flavor]
+	for _, opt := range []struct {
+		name, val string
+		ok        bool
+	}{
+		{"format", o.Format, format},
+		{"flavor", o.Flavor, flavor || o.Flavor == ""},
+		{"admonition", o.Admonition, o.Admonition == "" || style(admonitionStyles, o.Admonition) >= 0},
+		{"dl", o.DefinitionList, o.DefinitionList == "" || style(definitionListStyles, o.DefinitionList) >= 0},
+		{"details", o.Details, o.Details == "" || style(detailsStyles, o.Details) >= 0},
+		{"figure", o.Figure, o.Figure == "" || style(figureStyles, o.Figure) >= 0},
+		{"image-attrs", o.ImageAttrs, o.ImageAttrs == "" || style(imageAttrStyles, o.ImageAttrs) >= 0},
+	} {
+		if !opt.ok {
+			return fmt.Errorf("invalid -%s %q", opt.name, opt.val)
+		}
+	}
+	for class, kind := range o.AdmonitionClasses {
+		if h2md.NewConverter().SetAdmonitionClass(class, kind) != nil {
+			return fmt.Errorf("invalid -admonition-class %q", class+"="+kind)
+		}
+	}
+	if o.BaseURL != "" {
+		if _, err := url.Parse(o.BaseURL); err != nil {
+			return fmt.Errorf("invalid -base-url: %v", err)
+		}
+	}
+	if o.Charset != "" {
+		if enc, _ := charset.Lookup(o.Charset); enc == nil {
+			return fmt.Errorf("invalid -charset %q", o.Charset)
+		}
+	}
+	return nil
+}
+
+// ext return the extension of the written files
+func (o *options) ext() string {
+	if o.Ext != "" {
+		return "." + strings.TrimPrefix(o.Ext, ".")
+	}
+	return formatExts[o.Format]
+}
+
+// newH2MD return the converter of the html with the options applied
+func (o *options) newH2MD(src string) (*h2md.H2MD, error) {
+	var h *h2md.H2MD
+	var err error
+	if o.Confluence {
+		h, err = h2md.NewH2MDFromConfluence(src)
+	} else {
+		h, err = h2md.NewH2MDFromReader(strings.NewReader(src), &h2md.ReaderOptions{Charset: o.Charset})
+	}
+	if err != nil {
+		return nil, err
+	}
+	o.apply(h)
+	return h, nil
+}
+
+// apply set the options of the converter, the empty styles keep the ones of the flavor
+func (o *options) apply(h *h2md.H2MD) {
+	if f := flavors[o.Flavor]; f != nil {
+		h.SetFlavor(*f)
+	}
+	if r := formats[o.Format]; r != nil {
+		h.SetRenderer(r)
+	}
+	if i := style(admonitionStyles, o.Admonition); i >= 0 {
+		h.SetAdmonitionStyle(h2md.AdmonitionStyle(i))
+	}
+	for class, kind := range o.AdmonitionClasses {
+		h.SetAdmonitionClass(class, kind)
+	}
+	if i := style(definitionListStyles, o.DefinitionList); i >= 0 {
+		h.SetDefinitionListStyle(h2md.DefinitionListStyle(i))
+	}
+	if i := style(detailsStyles, o.Details); i >= 0 {
+		h.SetDetailsStyle(h2md.DetailsStyle(i))
+	}
+	if i := style(figureStyles, o.Figure); i >= 0 {
+		h.SetFigureStyle(h2md.FigureStyle(i))
+	}
+	if i := style(imageAttrStyles, o.ImageAttrs); i >= 0 {
+		h.SetImageAttrStyle(h2md.ImageAttrStyle(i))
+	}
+	h.SetImageMaxWidth(o.ImageMaxWidth)
+	h.SetEmbedRawHTML(o.EmbedHTML)
+	if o.BaseURL != "" {
+		// checked by check
+		_ = h.SetBaseURL(o.BaseURL)
+	}
+}
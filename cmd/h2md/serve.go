@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/diiyw/h2md"
+	"golang.org/x/net/html"
+)
+
+// runServe serve the conversions over http until interrupted
+func runServe(args []string, stderr io.Writer) int {
+	var addr string
+	var s server
+	o, fs, code := parseFlags(args, stderr, "serve [flags]", func(fs *flag.FlagSet) {
+		fs.StringVar(&addr, "addr", "localhost:8080", "listen on the `address`")
+		fs.Int64Var(&s.maxBytes, "max-bytes", 10<<20, "largest request body in `bytes`")
+		fs.DurationVar(&s.timeout, "timeout", 30*time.Second, "longest conversion, waiting for a worker included")
+		fs.IntVar(&s.concurrency, "concurrency", runtime.NumCPU(), "number of conversions at once")
+	})
+	if fs == nil {
+		return code
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	s.options = o
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       s.timeout,
+		WriteTimeout:      2 * s.timeout,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	fmt.Fprintln(stderr, "h2md: listening on", addr)
+	select {
+	case err := <-errc:
+		fmt.Fprintln(stderr, "h2md:", err)
+		return exitFailure
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		fmt.Fprintln(stderr, "h2md:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// server http conversion service
+type server struct {
+	// options defaults of the conversions, requests override them
+	options *options
+	// maxBytes largest request body
+	maxBytes int64
+	// timeout longest conversion, waiting for a worker included
+	timeout time.Duration
+	// concurrency number of conversions at once
+	concurrency int
+
+	slots   chan struct{}
+	metrics metrics
+}
+
+// errConversionPanic error of a conversion that panicked
+var errConversionPanic = errors.New("conversion panicked")
+
+// requestFlags flags a request may set, the ones reading or writing files are left out
+var requestFlags = map[string]bool{
+	"format": true, "flavor": true, "confluence": true, "base-url": true, "charset": true,
+	"admonition": true, "admonition-class": true, "dl": true, "details": true, "figure": true,
+	"image-attrs": true, "image-max-width": true, "embed-html": true,
+}
+
+// convertRequest json body of a conversion
+type convertRequest struct {
+	// HTML page to convert
+	HTML string `json:"html"`
+	// Options override the defaults of the server
+	Options json.RawMessage `json:"options"`
+}
+
+// convertResponse json response of a conversion
+type convertResponse struct {
+	Markdown    string  `json:"markdown"`
+	Title       string  `json:"title,omitempty"`
+	Format      string  `json:"format"`
+	Flavor      string  `json:"flavor,omitempty"`
+	InputBytes  int     `json:"input_bytes"`
+	OutputBytes int     `json:"output_bytes"`
+	Seconds     float64 `json:"seconds"`
+}
+
+// httpError error answered with the status code
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+// handler return the handler of /convert, /healthz and /metrics
+func (s *server) handler() http.Handler {
+	if s.concurrency <= 0 {
+		s.concurrency = 1
+	}
+	s.slots = make(chan struct{}, s.concurrency)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", s.convert)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		s.metrics.write(w)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+		path := r.URL.Path
+		if _, pattern := mux.Handler(r); pattern != path {
+			// unknown paths share a label
+			path = "other"
+		}
+		s.metrics.request(path, rec.code)
+	})
+}
+
+// convert answer the markdown of the html body, multipart file or json request
+func (s *server) convert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	start := time.Now()
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBytes)
+	src, o, err := s.read(r)
+	if err != nil {
+		var he *httpError
+		if !errors.As(err, &he) {
+			he = &httpError{http.StatusBadRequest, err.Error()}
+		}
+		http.Error(w, he.msg, he.code)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		http.Error(w, "too many conversions", http.StatusServiceUnavailable)
+		return
+	}
+	type result struct {
+		text, title string
+		err         error
+	}
+	done := make(chan result, 1)
+	s.metrics.inFlight(1)
+	go func() {
+		// the slot is held until the conversion ends, even after a timeout,
+		// a panicking conversion fails its request, not the server
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("%w: %v", errConversionPanic, p)}
+			}
+			<-s.slots
+			s.metrics.inFlight(-1)
+		}()
+		h, err := o.newH2MD(src)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		// the request body limit bounds the input, the other limits guard against hostile html
+		limits := h2md.DefaultLimits
+		limits.MaxInputBytes = 0
+		h.SetLimits(limits)
+		text, _, err := h.ConvertContext(ctx)
+		done <- result{text: text, title: title(h.Node), err: err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+	}
+	if ctx.Err() != nil {
+		http.Error(w, "conversion timed out", http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(res.err, errConversionPanic) {
+		http.Error(w, "conversion failed", http.StatusInternalServerError)
+		return
+	}
+	if res.err != nil {
+		http.Error(w, res.err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	elapsed := time.Since(start)
+	s.metrics.conversion(len(src), len(res.text), elapsed)
+
+	if r.URL.Query().Get("output") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(convertResponse{
+			Markdown:    res.text,
+			Title:       res.title,
+			Format:      o.Format,
+			Flavor:      o.Flavor,
+			InputBytes:  len(src),
+			OutputBytes: len(res.text),
+			Seconds:     elapsed.Seconds(),
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	io.WriteString(w, res.text)
+}
+
+// read return the html of the request and the options of the query and json body
+func (s *server) read(r *http.Request) (string, *options, error) {
+	o := *s.options
+	o.AdmonitionClasses = make(map[string]string)
+	for class, kind := range s.options.AdmonitionClasses {
+		o.AdmonitionClasses[class] = kind
+	}
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	bindFlags(fs, &o)
+	query := r.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "output" {
+			continue
+		}
+		if !requestFlags[name] {
+			return "", nil, fmt.Errorf("unknown option %q", name)
+		}
+		for _, val := range query[name] {
+			if err := fs.Set(name, val); err != nil {
+				return "", nil, fmt.Errorf("invalid option %s: %v", name, err)
+			}
+		}
+	}
+
+	var src []byte
+	var err error
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		var f io.ReadCloser
+		f, _, err = r.FormFile("file")
+		if err == nil {
+			src, err = io.ReadAll(f)
+			f.Close()
+		}
+	case strings.HasPrefix(contentType, "application/json"):
+		var req convertRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err == nil {
+			src = []byte(req.HTML)
+			if len(req.Options) > 0 {
+				err = json.Unmarshal(req.Options, &o)
+			}
+		}
+	default:
+		src, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return "", nil, &httpError{http.StatusRequestEntityTooLarge, "request body too large"}
+		}
+		return "", nil, err
+	}
+	if err := o.check(); err != nil {
+		return "", nil, err
+	}
+	return string(src), &o, nil
+}
+
+// title return the text of the title element
+func title(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "title" && n.FirstChild != nil {
+		return strings.TrimSpace(n.FirstChild.Data)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if t := title(c); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// statusRecorder response writer keeping the status code
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// metrics counters of the service written in the Prometheus text format
+type metrics struct {
+	mu          sync.Mutex
+	requests    map[[2]string]int
+	conversions int
+	seconds     float64
+	inputBytes  int
+	outputBytes int
+	running     int
+}
+
+// request count the answered request
+func (m *metrics) request(path string, code int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests == nil {
+		m.requests = make(map[[2]string]int)
+	}
+	m.requests[[2]string{path, strconv.Itoa(code)}]++
+}
+
+// conversion count the conversion
+func (m *metrics) conversion(in, out int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conversions++
+	m.seconds += elapsed.Seconds()
+	m.inputBytes += in
+	m.outputBytes += out
+}
+
+// inFlight add to the number of running conversions
+func (m *metrics) inFlight(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running += n
+}
+
+// write write the metrics
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintln(w, "# HELP h2md_http_requests_total Answered http requests.")
+	fmt.Fprintln(w, "# TYPE h2md_http_requests_total counter")
+	keys := make([][2]string, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "h2md_http_requests_total{path=%q,code=%q} %d\n", k[0], k[1], m.requests[k])
+	}
+	fmt.Fprintln(w, "# HELP h2md_conversion_seconds Time spent converting, reading the request included.")
+	fmt.Fprintln(w, "# TYPE h2md_conversion_seconds summary")
+	fmt.Fprintf(w, "h2md_conversion_seconds_sum %g\n", m.seconds)
+	fmt.Fprintf(w, "h2md_conversion_seconds_count %d\n", m.conversions)
+	fmt.Fprintln(w, "# HELP h2md_input_bytes_total Html bytes converted.")
+	fmt.Fprintln(w, "# TYPE h2md_input_bytes_total counter")
+	fmt.Fprintf(w, "h2md_input_bytes_total %d\n", m.inputBytes)
+	fmt.Fprintln(w, "# HELP h2md_output_bytes_total Bytes written by the conversions.")
+	fmt.Fprintln(w, "# TYPE h2md_output_bytes_total counter")
+	fmt.Fprintf(w, "h2md_output_bytes_total %d\n", m.outputBytes)
+	fmt.Fprintln(w, "# HELP h2md_conversions_in_flight Running conversions.")
+	fmt.Fprintln(w, "# TYPE h2md_conversions_in_flight gauge")
+	fmt.Fprintf(w, "h2md_conversions_in_flight %d\n", m.running)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runWatch convert the directory tree, then convert the html files again as they are written
+// and remove the outputs of the removed ones until interrupted
+func runWatch(args []string, stderr io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return watch(ctx, args, stderr)
+}
+
+// watch run the watch command until the context is done
+func watch(ctx context.Context, args []string, stderr io.Writer) int {
+	var debounce time.Duration
+	o, fs, code := parseFlags(args, stderr, "watch [flags] -o dir dir", func(fs *flag.FlagSet) {
+		fs.DurationVar(&debounce, "debounce", 200*time.Millisecond, "convert a file once its writes paused for the `duration`")
+	})
+	if fs == nil {
+		return code
+	}
+	if fs.NArg() != 1 || o.Output == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	root := fs.Arg(0)
+	if _, err := convertDir(ctx, root, o.Output, newBatch(o), stderr); err != nil {
+		return exitFailure
+	}
+
+	changes := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- watchTree(ctx, root, changes)
+	}()
+	// the changed paths wait until no change came for the debounce duration
+	pending := make(map[string]bool)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return exitOK
+		case err := <-errc:
+			if err != nil {
+				fmt.Fprintln(stderr, "h2md:", err)
+				return exitFailure
+			}
+			return exitOK
+		case path := <-changes:
+			pending[path] = true
+			timer.Reset(debounce)
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			pending = make(map[string]bool)
+			for _, path := range paths {
+				update(o, root, path, stderr)
+			}
+		}
+	}
+}
+
+// update convert the changed html file, or remove the outputs of the removed file or directory
+func update(o *options, root, path string, stderr io.Writer) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	isHTML := htmlExts[strings.ToLower(filepath.Ext(path))]
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		if info.IsDir() || !isHTML {
+			return
+		}
+		if err := convertFile(o, input{path: path, rel: rel}, nil, nil); err != nil {
+			fmt.Fprintln(stderr, "h2md:", err)
+			return
+		}
+		fmt.Fprintln(stderr, "converted", rel)
+	case os.IsNotExist(err):
+		out := filepath.Join(o.Output, rel)
+		if isHTML {
+			out = outputPath(o, rel)
+		} else if info, err := os.Stat(out); err != nil || !info.IsDir() {
+			return
+		}
+		if err := os.RemoveAll(out); err != nil {
+			fmt.Fprintln(stderr, "h2md:", err)
+			return
+		}
+		fmt.Fprintln(stderr, "removed", out)
+	}
+}
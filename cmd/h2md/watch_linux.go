@@ -0,0 +1,108 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask events of the watched directories
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// watchTree send the paths created, written, moved or removed under the root with inotify
+// until the context is done, new directories are watched as they appear
+func watchTree(ctx context.Context, root string, changes chan<- string) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	// a non blocking descriptor is handled by the runtime poller, closing it ends the read
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	send := func(path string) {
+		select {
+		case changes <- path:
+		case <-ctx.Done():
+		}
+	}
+	dirs := make(map[int32]string)
+	// add watch the directory and its subdirectories, sending the files found
+	// when they moved in after their directory was created
+	add := func(dir string, found bool) error {
+		return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// removed in the meantime
+				return nil
+			}
+			if !info.IsDir() {
+				if found {
+					send(path)
+				}
+				return nil
+			}
+			wd, err := syscall.InotifyAddWatch(fd, path, inotifyMask)
+			if err != nil {
+				return os.NewSyscallError("inotify_add_watch", err)
+			}
+			dirs[int32(wd)] = path
+			return nil
+		})
+	}
+	if err := add(root, false); err != nil {
+		return err
+	}
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for i := 0; i+syscall.SizeofInotifyEvent <= n; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[i]))
+			nameBytes := buf[i+syscall.SizeofInotifyEvent : i+syscall.SizeofInotifyEvent+int(e.Len)]
+			i += syscall.SizeofInotifyEvent + int(e.Len)
+			dir, ok := dirs[e.Wd]
+			if !ok {
+				continue
+			}
+			if e.Mask&(syscall.IN_IGNORED|syscall.IN_DELETE_SELF) != 0 {
+				delete(dirs, e.Wd)
+				continue
+			}
+			path := filepath.Join(dir, cString(nameBytes))
+			switch {
+			case e.Mask&syscall.IN_ISDIR != 0 && e.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+				if err := add(path, true); err != nil {
+					return err
+				}
+			case e.Mask&syscall.IN_CREATE != 0:
+				// the write that follows is reported when the file is closed
+			default:
+				send(path)
+			}
+		}
+	}
+}
+
+// cString return the string before the nul padding
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
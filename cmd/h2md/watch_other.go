@@ -0,0 +1,61 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval time between two scans of the watched tree
+const pollInterval = 500 * time.Millisecond
+
+// watchTree send the paths created, written or removed under the root until the context is done,
+// the tree is scanned for changed modification times and sizes
+func watchTree(ctx context.Context, root string, changes chan<- string) error {
+	type stamp struct {
+		mod  time.Time
+		size int64
+	}
+	scan := func() map[string]stamp {
+		files := make(map[string]stamp)
+		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				files[path] = stamp{info.ModTime(), info.Size()}
+			}
+			return nil
+		})
+		return files
+	}
+	send := func(path string) {
+		select {
+		case changes <- path:
+		case <-ctx.Done():
+		}
+	}
+	files := scan()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current := scan()
+		for path, s := range current {
+			if old, ok := files[path]; !ok || old != s {
+				send(path)
+			}
+		}
+		for path := range files {
+			if _, ok := current[path]; !ok {
+				send(path)
+			}
+		}
+		files = current
+	}
+}
@@ -0,0 +1,305 @@
+package h2md
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// confluenceCDATA plain text bodies of code macros
+	confluenceCDATA = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+	// confluenceSelfClosing self-closing storage format tags html parsing would leave open
+	confluenceSelfClosing = regexp.MustCompile(`<((?:ac|ri):[a-zA-Z-]+)(\s[^>]*?)?\s*/>`)
+)
+
+// confluencePanels information macros and the class suffix of their rendered html
+var confluencePanels = map[string]string{
+	"info":    "information",
+	"note":    "note",
+	"warning": "warning",
+	"tip":     "tip",
+	"panel":   "",
+}
+
+// confluenceDropped macros without content to convert
+var confluenceDropped = map[string]bool{
+	"toc": true, "anchor": true, "children": true, "pagetree": true,
+	"recently-updated": true, "excerpt-include": true, "contentbylabel": true,
+}
+
+// confluenceEmoticons emoji of the ac:emoticon names
+var confluenceEmoticons = map[string]string{
+	"smile": "🙂", "sad": "🙁", "cheeky": "😛", "laugh": "😀", "wink": "😉",
+	"thumbs-up": "👍", "thumbs-down": "👎", "information": "ℹ️", "tick": "✅",
+	"cross": "❌", "warning": "⚠️", "plus": "➕", "minus": "➖", "question": "❓",
+	"light-on": "💡", "light-off": "💡", "yellow-star": "⭐", "red-star": "⭐",
+	"green-star": "⭐", "blue-star": "⭐", "heart": "❤️", "broken-heart": "💔",
+}
+
+// NewH2MDFromConfluence create H2MD with a Confluence storage format page,
+// its macros, images, links and task lists are turned into html
+func NewH2MDFromConfluence(storage string) (*H2MD, error) {
+	size := len(storage)
+	storage = confluenceCDATA.ReplaceAllStringFunc(storage, func(s string) string {
+		return html.EscapeString(s[len("<![CDATA[") : len(s)-len("]]>")])
+	})
+	storage = confluenceSelfClosing.ReplaceAllString(storage, "<$1$2></$1>")
+	h, err := NewH2MD(storage)
+	if err != nil {
+		return nil, err
+	}
+	confluence(h.Node)
+	// the rewritten tree no longer matches the storage, its warnings have no position
+	h.source = ""
+	h.size = size
+	return h, nil
+}
+
+// confluence replace the storage format elements under the node by html
+func confluence(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		r, ok := confluenceNode(c)
+		if !ok {
+			confluence(c)
+			c = next
+			continue
+		}
+		if r != nil {
+			n.InsertBefore(r, c)
+			confluence(r)
+		}
+		n.RemoveChild(c)
+		c = next
+	}
+}
+
+// element return a new element with the key value attributes
+func element(tag string, attrs ...string) *html.Node {
+	n := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag))}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.Attr = append(n.Attr, html.Attribute{Key: attrs[i], Val: attrs[i+1]})
+	}
+	return n
+}
+
+// appendText append a text node to the element
+func appendText(n *html.Node, text string) *html.Node {
+	n.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	return n
+}
+
+// moveChildren move the children of src to the end of dst
+func moveChildren(dst, src *html.Node) {
+	if src == nil {
+		return
+	}
+	for c := src.FirstChild; c != nil; {
+		next := c.NextSibling
+		src.RemoveChild(c)
+		dst.AppendChild(c)
+		c = next
+	}
+}
+
+// childElement return the first child element of the tag
+func childElement(n *html.Node, tag string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// macroParams return the ac:parameter values of the macro
+func macroParams(n *html.Node) map[string]string {
+	params := make(map[string]string)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "ac:parameter" {
+			params[attr(c, "ac:name")] = strings.TrimSpace(textContent(c))
+		}
+	}
+	return params
+}
+
+// confluenceNode return the html replacing the storage format element, nil drops it,
+// ok is false for other elements
+func confluenceNode(n *html.Node) (r *html.Node, ok bool) {
+	if n.Type != html.ElementNode || !strings.HasPrefix(n.Data, "ac:") {
+		return nil, false
+	}
+	switch n.Data {
+	case "ac:structured-macro", "ac:macro":
+		return confluenceMacro(n), true
+	case "ac:image":
+		return confluenceImage(n), true
+	case "ac:link":
+		return confluenceLink(n), true
+	case "ac:task-list":
+		ul := element("ul")
+		for task := n.FirstChild; task != nil; task = task.NextSibling {
+			if task.Type != html.ElementNode || task.Data != "ac:task" {
+				continue
+			}
+			box := element("input", "type", "checkbox")
+			if status := childElement(task, "ac:task-status"); status != nil && strings.TrimSpace(textContent(status)) == "complete" {
+				box.Attr = append(box.Attr, html.Attribute{Key: "checked"})
+			}
+			li := element("li")
+			li.AppendChild(box)
+			appendText(li, " ")
+			moveChildren(li, childElement(task, "ac:task-body"))
+			ul.AppendChild(li)
+		}
+		return ul, true
+	case "ac:emoticon":
+		name := attr(n, "ac:name")
+		if emoji, ok := confluenceEmoticons[name]; ok {
+			return &html.Node{Type: html.TextNode, Data: emoji}, true
+		}
+		return &html.Node{Type: html.TextNode, Data: ":" + name + ":"}, true
+	case "ac:placeholder", "ac:parameter":
+		return nil, true
+	}
+	// layouts, inline comment markers and the like keep their content
+	wrapper := element("span")
+	if strings.HasPrefix(n.Data, "ac:layout") {
+		wrapper = element("div")
+	}
+	moveChildren(wrapper, n)
+	return wrapper, true
+}
+
+// confluenceMacro return the html of the structured macro
+func confluenceMacro(n *html.Node) *html.Node {
+	name := attr(n, "ac:name")
+	params := macroParams(n)
+	rich := childElement(n, "ac:rich-text-body")
+	plain := childElement(n, "ac:plain-text-body")
+	if suffix, ok := confluencePanels[name]; ok {
+		class := "confluence-information-macro"
+		if suffix != "" {
+			class += " confluence-information-macro-" + suffix
+		}
+		div := element("div", "class", class)
+		if title := params["title"]; title != "" {
+			div.AppendChild(appendText(element("p", "class", "title"), title))
+		}
+		moveChildren(div, rich)
+		return div
+	}
+	switch {
+	case confluenceDropped[name]:
+		return nil
+	case name == "code" || name == "noformat":
+		code := element("code")
+		if lang := params["language"]; lang != "" {
+			code.Attr = append(code.Attr, html.Attribute{Key: "class", Val: "language-" + lang})
+		}
+		var text string
+		if plain != nil {
+			text = textContent(plain)
+		}
+		pre := element("pre")
+		pre.AppendChild(appendText(code, strings.Trim(text, "\n")))
+		return pre
+	case name == "expand":
+		title := params["title"]
+		if title == "" {
+			title = "Click here to expand..."
+		}
+		details := element("details")
+		details.AppendChild(appendText(element("summary"), title))
+		moveChildren(details, rich)
+		return details
+	case name == "status":
+		title := params["title"]
+		if title == "" {
+			title = params["colour"]
+		}
+		return appendText(element("strong"), strings.ToUpper(title))
+	case name == "jira":
+		key := params["key"]
+		if key == "" {
+			return nil
+		}
+		if server := strings.TrimRight(params["server"], "/"); strings.HasPrefix(server, "http") {
+			return appendText(element("a", "href", server+"/browse/"+key), key)
+		}
+		return &html.Node{Type: html.TextNode, Data: key}
+	case rich != nil:
+		div := element("div")
+		moveChildren(div, rich)
+		return div
+	case plain != nil:
+		return appendText(element("pre"), textContent(plain))
+	}
+	return nil
+}
+
+// confluenceImage return the img of the attachment or url image
+func confluenceImage(n *html.Node) *html.Node {
+	var src string
+	if a := descendant(n, "ri:attachment"); a != nil {
+		src = attr(a, "ri:filename")
+	} else if u := descendant(n, "ri:url"); u != nil {
+		src = attr(u, "ri:value")
+	}
+	if src == "" {
+		return nil
+	}
+	img := element("img", "src", src)
+	for _, a := range [][2]string{{"ac:alt", "alt"}, {"ac:title", "title"}, {"ac:width", "width"}, {"ac:height", "height"}, {"ac:align", "align"}} {
+		if v, ok := attrOk(n, a[0]); ok {
+			img.Attr = append(img.Attr, html.Attribute{Key: a[1], Val: v})
+		}
+	}
+	return img
+}
+
+// confluenceLink return the a of the page, attachment or url link, users become @name
+func confluenceLink(n *html.Node) *html.Node {
+	var href, text string
+	if page := descendant(n, "ri:page"); page != nil {
+		text = attr(page, "ri:content-title")
+		href = text
+	} else if a := descendant(n, "ri:attachment"); a != nil {
+		text = attr(a, "ri:filename")
+		href = text
+	} else if u := descendant(n, "ri:url"); u != nil {
+		href = attr(u, "ri:value")
+		text = href
+	} else if user := descendant(n, "ri:user"); user != nil {
+		name := attr(user, "ri:username")
+		if name == "" {
+			name = attr(user, "ri:userkey")
+		}
+		return &html.Node{Type: html.TextNode, Data: "@" + name}
+	}
+	if anchor := attr(n, "ac:anchor"); anchor != "" {
+		href += "#" + anchor
+		if text == "" {
+			text = anchor
+		}
+	}
+	a := element("a", "href", href)
+	if body := descendant(n, "ac:link-body"); body != nil {
+		moveChildren(a, body)
+		return a
+	}
+	if body := descendant(n, "ac:plain-text-link-body"); body != nil {
+		if t := strings.TrimSpace(textContent(body)); t != "" {
+			text = t
+		}
+	}
+	return appendText(a, text)
+}
+
+// descendant return the first descendant element of the tag
+func descendant(n *html.Node, tag string) *html.Node {
+	return findElement(n, func(e *html.Node) bool { return e.Data == tag })
+}
@@ -0,0 +1,941 @@
+package h2md
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockTags elements laid out as blocks
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true,
+	"caption": true, "center": true, "dd": true, "details": true, "dialog": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hgroup": true, "hr": true, "html": true, "legend": true, "li": true,
+	"main": true, "menu": true, "nav": true, "ol": true, "p": true, "pre": true, "search": true,
+	"section": true, "summary": true, "table": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "tr": true, "ul": true,
+}
+
+// ignoredTags elements without content to convert
+var ignoredTags = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"button": true, "select": true, "textarea": true, "input": true, "svg": true,
+	"canvas": true, "map": true, "meta": true, "link": true, "title": true, "source": true,
+}
+
+// inlineTags inline elements converted or walked for their content
+var inlineTags = map[string]bool{
+	"a": true, "img": true, "picture": true, "em": true, "i": true, "cite": true, "var": true,
+	"dfn": true, "strong": true, "b": true, "del": true, "s": true, "strike": true, "code": true,
+	"tt": true, "samp": true, "q": true, "br": true, "wbr": true, "span": true, "label": true,
+	"time": true, "data": true, "bdi": true, "bdo": true, "font": true, "big": true, "nobr": true,
+	"ruby": true, "rt": true, "rp": true, "video": true, "audio": true, "iframe": true,
+	"embed": true, "object": true, "param": true, "track": true, "math": true,
+	"mjx-container": true, "colgroup": true, "col": true, "area": true, "output": true,
+	"meter": true, "progress": true, "base": true,
+}
+
+// droppedTags elements dropped with their content and what is lost
+var droppedTags = map[string]string{
+	"svg":      "svg drawing dropped",
+	"canvas":   "canvas dropped",
+	"textarea": "textarea content dropped",
+	"select":   "select options dropped",
+}
+
+// rawInlineTags inline elements without markdown syntax, kept as raw html when allowed
+var rawInlineTags = map[string]bool{
+	"sub": true, "sup": true, "kbd": true, "mark": true, "u": true, "ins": true,
+	"small": true, "abbr": true,
+}
+
+// codeClasses classes of code elements that are not the language
+var codeClasses = map[string]bool{
+	"hljs": true, "prism": true, "highlight": true, "has-numbering": true, "prettyprint": true,
+	"linenums": true, "sourcecode": true, "notranslate": true, "code": true, "codehilite": true,
+	"chroma": true, "line-numbers": true, "nohighlight": true,
+}
+
+var (
+	whitespace    = regexp.MustCompile(`[ \t\n\r\f]+`)
+	orderedMarker = regexp.MustCompile(`^(\d+)([.)])`)
+	entity        = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+	backticks     = regexp.MustCompile("`+")
+)
+
+// walker state of one conversion walking the tree for the renderer
+type walker struct {
+	h  *Converter
+	in *interrupt
+	r  Renderer
+	f  *Flavor
+	md bool
+	// spaced the renderer writes the line breaks around its blocks, as the default
+	// output does, so the blocks are joined as they are
+	spaced   bool
+	w        *warner
+	fs       *footnotes
+	skip     map[*html.Node]bool
+	captions map[*html.Node]string
+}
+
+// render return the output of the renderer for the tree, markdown of the flavor when none is set
+// and the default output without flavor either, the warner collects what the output loses
+func (h *Converter) render(root *html.Node, in *interrupt, w *warner) string {
+	c := &walker{
+		h:        h,
+		in:       in,
+		w:        w,
+		r:        h.renderer,
+		f:        &Flavor{},
+		skip:     make(map[*html.Node]bool),
+		captions: make(map[*html.Node]string),
+	}
+	switch {
+	case c.r != nil:
+	case h.flavor != nil:
+		c.f = h.flavor
+		c.r = &markdown{h: h, f: h.flavor}
+		c.md = true
+	default:
+		c.f = &defaultFlavor
+		c.r = &defaultMarkdown{h: h}
+		c.md = true
+		c.spaced = true
+	}
+	if c.f.Footnotes {
+		c.fs = collectFootnotes(root)
+	} else {
+		c.fs = &footnotes{}
+	}
+	text := c.blocks(root, false)
+	for _, fn := range c.fs.list {
+		text += "\n\n[^" + fn.label + "]: " + indent(strings.Trim(c.blocks(fn.node, false), "\n"), "    ")
+	}
+	if c.spaced {
+		return text
+	}
+	return strings.Trim(text, "\n")
+}
+
+// isBlock report whether the node is laid out as a block
+func (c *walker) isBlock(n *html.Node) bool {
+	if n.Type == html.DocumentNode {
+		return true
+	}
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch {
+	case blockTags[n.Data], hasClass(n, "katex-display"):
+		return true
+	case n.Data == "math":
+		return attr(n, "display") == "block"
+	case n.Data == "mjx-container":
+		return attr(n, "display") == "true"
+	case n.Data == "script":
+		return strings.HasPrefix(attr(n, "type"), "math/tex") && strings.Contains(attr(n, "type"), "mode=display")
+	case n.Data == "head":
+		// the parser moves a math script starting the page into the head
+		return findElement(n, func(e *html.Node) bool {
+			return e.Data == "script" && strings.HasPrefix(attr(e, "type"), "math/tex")
+		}) != nil
+	case n.Data == "a", n.Data == "img", ignoredTags[n.Data]:
+		return false
+	}
+	// inline elements wrapping blocks, e.g. <span><p>
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if c.isBlock(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+// chunks return the rendered blocks of the children, inline runs become paragraphs
+func (c *walker) chunks(n *html.Node, tight bool) []string {
+	var chunks []string
+	var run strings.Builder
+	// size running total of the chunks, the walk stops once it is beyond the output limit
+	var size int
+	var flush = func() {
+		if p := c.r.Paragraph(run.String()); p != "" {
+			chunks = append(chunks, p)
+			size += len(p)
+		}
+		run.Reset()
+	}
+	for ch := n.FirstChild; ch != nil && !c.in.written(size+run.Len()); ch = ch.NextSibling {
+		if !c.isBlock(ch) {
+			run.WriteString(c.inline(ch))
+			continue
+		}
+		flush()
+		s := c.block(ch, tight)
+		if !c.spaced {
+			s = strings.Trim(s, "\n")
+		}
+		if s != "" {
+			chunks = append(chunks, s)
+			size += len(s)
+		}
+	}
+	flush()
+	return chunks
+}
+
+// quotedBlock rendered blocks of a blockquote nested at the depth, separated from the
+// previous ones by the blockquote at the depth sep
+type quotedBlock struct {
+	depth int
+	sep   int
+	text  string
+}
+
+// lineQuoter renderer quoting the blocks of nested blockquotes in one pass, so each line
+// is prefixed once rather than once per level
+type lineQuoter interface {
+	quoteBlocks(blocks []quotedBlock, in *interrupt) string
+}
+
+// quote return the block quote, with its nested blockquotes quoted in one pass when the
+// renderer can
+func (c *walker) quote(n *html.Node) string {
+	q, ok := c.r.(lineQuoter)
+	if !ok {
+		return c.r.Quote(c.blocks(n, false))
+	}
+	var blocks []quotedBlock
+	c.quotedBlocks(n, 1, &blocks)
+	return q.quoteBlocks(blocks, c.in)
+}
+
+// quotedBlocks append the blocks of the blockquote at the depth, those of the blockquotes
+// nested in it one level deeper, an empty blockquote has one empty block unless the blocks
+// are spaced, the default output dropping it
+func (c *walker) quotedBlocks(n *html.Node, depth int, blocks *[]quotedBlock) {
+	if c.in.done() {
+		return
+	}
+	start := len(*blocks)
+	var chunks []string
+	var run strings.Builder
+	var size int
+	var paragraph = func() {
+		if p := c.r.Paragraph(run.String()); p != "" {
+			chunks = append(chunks, p)
+			size += len(p)
+		}
+		run.Reset()
+	}
+	var flush = func() {
+		if len(chunks) > 0 {
+			*blocks = append(*blocks, quotedBlock{depth: depth, sep: depth, text: c.join(chunks, false)})
+			chunks = nil
+		}
+	}
+	for ch := n.FirstChild; ch != nil && !c.in.written(size+run.Len()); ch = ch.NextSibling {
+		if !c.isBlock(ch) {
+			run.WriteString(c.inline(ch))
+			continue
+		}
+		paragraph()
+		if ch.Type == html.ElementNode && ch.Data == "blockquote" && !c.skip[ch] && !c.fs.containers[ch] {
+			pending, at := chunks, len(*blocks)
+			flush()
+			i := len(*blocks)
+			c.quotedBlocks(ch, depth+1, blocks)
+			if len(*blocks) == i {
+				// the empty blockquote of spaced blocks joins the blocks around it
+				*blocks, chunks = (*blocks)[:at], pending
+				continue
+			}
+			if i > start {
+				(*blocks)[i].sep = depth
+			}
+			for _, b := range (*blocks)[i:] {
+				size += len(b.text)
+			}
+			continue
+		}
+		s := c.block(ch, false)
+		if !c.spaced {
+			s = strings.Trim(s, "\n")
+		}
+		if s != "" {
+			chunks = append(chunks, s)
+			size += len(s)
+		}
+	}
+	paragraph()
+	flush()
+	if len(*blocks) == start && !c.spaced {
+		*blocks = append(*blocks, quotedBlock{depth: depth, sep: depth})
+	}
+}
+
+// blocks return the rendered blocks of the children separated by blank lines,
+// or by line breaks when tight
+func (c *walker) blocks(n *html.Node, tight bool) string {
+	return c.join(c.chunks(n, tight), tight)
+}
+
+// join join the blocks, the spaced ones as they are unless neither ends or starts a line
+func (c *walker) join(chunks []string, tight bool) string {
+	if !c.spaced {
+		return joinBlocks(chunks, tight)
+	}
+	var b strings.Builder
+	for i, chunk := range chunks {
+		if i > 0 && !strings.HasSuffix(b.String(), "\n") && !strings.HasPrefix(chunk, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(chunk)
+	}
+	return b.String()
+}
+
+// spacedBlock return the block built by the walker led by a blank line and ended
+// by a line break when the blocks are spaced, the way the default output writes them
+func (c *walker) spacedBlock(s string) string {
+	if !c.spaced || s == "" {
+		return s
+	}
+	return "\n\n" + s + "\n"
+}
+
+// joinBlocks join the blocks separated by blank lines, or by line breaks when tight
+func joinBlocks(chunks []string, tight bool) string {
+	if !tight {
+		return strings.Join(chunks, "\n\n")
+	}
+	var s string
+	for i, chunk := range chunks {
+		if i > 0 {
+			// a thematic break after a paragraph line would make a setext heading
+			if chunk == "---" || chunks[i-1] == "---" {
+				s += "\n"
+			}
+			s += "\n"
+		}
+		s += chunk
+	}
+	return s
+}
+
+// block return the output of the block element
+func (c *walker) block(n *html.Node, tight bool) string {
+	if c.in.done() {
+		return ""
+	}
+	if n.Type == html.DocumentNode {
+		return c.blocks(n, tight)
+	}
+	if c.skip[n] || c.fs.containers[n] || c.fs.isBacklink(n) || isMathJaxOutput(n) {
+		return ""
+	}
+	if hasClass(n, "katex-display") {
+		return c.spacedBlock(c.math(mathTeX(n), true))
+	}
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Data[1:])
+		return c.heading(level, n)
+	case "hr":
+		return c.r.ThematicBreak()
+	case "pre":
+		return c.codeBlock(n)
+	case "blockquote":
+		return c.quote(n)
+	case "ul", "ol":
+		return c.list(n)
+	case "table":
+		return c.table(n)
+	case "dl":
+		return c.spacedBlock(c.definitionList(n))
+	case "details":
+		return c.spacedBlock(c.details(n))
+	case "figure":
+		return c.figure(n, tight)
+	case "math":
+		return c.spacedBlock(c.math(mathTeX(n), true))
+	case "mjx-container":
+		return c.spacedBlock(c.math(mathTeX(n), true))
+	case "script":
+		return c.spacedBlock(c.math(strings.TrimSpace(textContent(n)), true))
+	case "div", "aside", "section":
+		if kind, ok := c.h.admonitionKind(n); ok {
+			var title string
+			if t := admonitionTitle(n); t != nil {
+				title = strings.TrimSpace(c.inlines(t))
+				c.skip[t] = true
+			}
+			return c.r.Admonition(kind, title, c.blocks(n, false))
+		}
+		if a := attributes(n); c.f.FencedDivs && n.Data == "div" && a != "" {
+			if text := c.blocks(n, false); text != "" {
+				return "::: " + a + "\n" + text + "\n:::"
+			}
+			return ""
+		}
+	}
+	c.w.unknown(n)
+	return c.blocks(n, tight)
+}
+
+// inlines return the inline output of the children
+func (c *walker) inlines(n *html.Node) string {
+	var s strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		s.WriteString(c.inline(ch))
+	}
+	return s.String()
+}
+
+// inline return the output of the inline node
+func (c *walker) inline(n *html.Node) string {
+	if c.in.done() {
+		return ""
+	}
+	if n.Type == html.TextNode {
+		if c.spaced {
+			// the default output keeps the line breaks of the text
+			return c.r.Escape(n.Data)
+		}
+		return c.r.Escape(whitespace.ReplaceAllString(n.Data, " "))
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	if ignoredTags[n.Data] && n.Data != "script" {
+		c.w.warn(n, WarnDropped, droppedTags[n.Data])
+		return ""
+	}
+	if c.skip[n] || c.fs.containers[n] || c.fs.isBacklink(n) || isMathJaxOutput(n) {
+		return ""
+	}
+	if hasClass(n, "katex") || hasClass(n, "katex-display") {
+		return c.math(mathTeX(n), false)
+	}
+	switch n.Data {
+	case "br":
+		return c.r.LineBreak()
+	case "a":
+		if fn := c.fs.ref(n); fn != nil {
+			return "[^" + fn.label + "]"
+		}
+		return c.link(n)
+	case "img":
+		return c.image(n)
+	case "em", "i", "cite", "var", "dfn":
+		return c.r.Emphasis(c.inlines(n))
+	case "strong", "b":
+		return c.r.Strong(c.inlines(n))
+	case "del", "s", "strike":
+		return c.r.Strikethrough(c.inlines(n))
+	case "code", "tt", "samp":
+		return c.r.Code(textContent(n))
+	case "q":
+		return "\"" + c.inlines(n) + "\""
+	case "math":
+		return c.math(mathTeX(n), false)
+	case "mjx-container":
+		return c.math(mathTeX(n), false)
+	case "script":
+		if strings.HasPrefix(attr(n, "type"), "math/tex") {
+			return c.math(strings.TrimSpace(textContent(n)), false)
+		}
+		return ""
+	case "video", "audio", "iframe", "embed", "object":
+		if embedSrc(n) == "" {
+			c.w.warn(n, WarnDropped, n.Data+" without src dropped")
+		}
+		if !c.md {
+			if src := embedSrc(n); src != "" {
+				return c.r.Link(c.r.Escape(embedTitle(n, src)), src, "")
+			}
+			return ""
+		}
+		if c.spaced {
+			return c.h.embed(n)
+		}
+		return strings.TrimSpace(c.h.embed(n))
+	case "sup":
+		if fn := c.fs.supRef(n); fn != nil {
+			return "[^" + fn.label + "]"
+		}
+		if c.f.Subscript {
+			return script("^", c.inlines(n))
+		}
+	case "sub":
+		if c.f.Subscript {
+			return script("~", c.inlines(n))
+		}
+	}
+	if rawInlineTags[n.Data] && c.f.RawHTML {
+		return "<" + n.Data + ">" + c.inlines(n) + "</" + n.Data + ">"
+	}
+	c.w.unknown(n)
+	return c.inlines(n)
+}
+
+func isAlnum(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// link return the link of the a element, or its text without href
+func (c *walker) link(n *html.Node) string {
+	text := c.inlines(n)
+	if _, ok := attrOk(n, "href"); !ok {
+		return text
+	}
+	return c.r.Link(strings.Trim(text, " "), c.h.Attr("href", n), attr(n, "title"))
+}
+
+// image return the image of the img element
+func (c *walker) image(n *html.Node) string {
+	c.w.warn(n, WarnImage, c.h.imageWarning(n))
+	src := c.h.imageSrc(n)
+	if src == "" {
+		return ""
+	}
+	alt, title := c.h.Attr("alt", n), c.h.Attr("title", n)
+	if caption, ok := c.captions[n]; ok {
+		if alt == "" {
+			alt = caption
+		} else if title == "" {
+			title = caption
+		}
+	}
+	if !c.md {
+		return c.r.Image(alt, src, title)
+	}
+	if c.spaced {
+		// the default output puts the images of paragraphs on their own line
+		if n.Parent != nil && n.Parent.Data == "p" {
+			return "\n" + c.h.image(n, alt, src, title) + "\n"
+		}
+		return c.h.image(n, alt, src, title)
+	}
+	if c.h.imageAttrStyle == ImageAttrHTML && c.f.RawHTML {
+		if s := c.h.image(n, alt, src, title); strings.HasPrefix(s, "<") {
+			return s
+		}
+	}
+	md := c.r.Image(alt, src, title)
+	if c.h.imageAttrStyle == ImageAttrBraces {
+		if s := c.h.image(n, "", "", ""); strings.HasSuffix(s, "}") {
+			md += s[strings.LastIndex(s, "{"):]
+		}
+	}
+	return md
+}
+
+// math return the TeX as math, or as code when the flavor has no math
+func (c *walker) math(tex string, display bool) string {
+	if c.f.Math {
+		return strings.Trim(texMath(tex, display), "\n")
+	}
+	if display {
+		return c.r.CodeBlock(tex, "math")
+	}
+	return c.r.Code(tex)
+}
+
+// heading return the heading, its line breaks become spaces
+func (c *walker) heading(level int, n *html.Node) string {
+	text := strings.ReplaceAll(c.inlines(n), c.r.LineBreak(), " ")
+	text = strings.Trim(whitespace.ReplaceAllString(text, " "), " ")
+	return c.r.Heading(level, text, strings.TrimSpace(attr(n, "id")), strings.Fields(attr(n, "class")))
+}
+
+// codeText return the text of the code block, dropping line numbers
+func codeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.Type != html.ElementNode && n.Type != html.DocumentNode {
+		return ""
+	}
+	if n.Data == "br" {
+		return "\n"
+	}
+	if hasClass(n, "pre-numbering") || hasClass(n, "line-numbers-rows") || hasClass(n, "hljs-button") {
+		return ""
+	}
+	var s strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		s.WriteString(codeText(ch))
+	}
+	return s.String()
+}
+
+// codeLanguage return the language of the code block from its classes
+func codeLanguage(nodes ...*html.Node) string {
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		if lang := attr(n, "data-lang"); lang != "" {
+			return lang
+		}
+		for _, class := range strings.Fields(attr(n, "class")) {
+			for _, prefix := range []string{"language-", "lang-", "highlight-source-"} {
+				if strings.HasPrefix(class, prefix) && len(class) > len(prefix) {
+					return class[len(prefix):]
+				}
+			}
+		}
+	}
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		for _, class := range strings.Fields(attr(n, "class")) {
+			if !codeClasses[strings.ToLower(class)] && !strings.Contains(class, "-") {
+				return class
+			}
+		}
+	}
+	return ""
+}
+
+// codeBlock return the code block of the pre element
+func (c *walker) codeBlock(n *html.Node) string {
+	var code *html.Node
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode && ch.Data == "code" {
+			code = ch
+			break
+		}
+	}
+	return c.r.CodeBlock(strings.TrimSuffix(codeText(n), "\n"), codeLanguage(code, n))
+}
+
+// isLoose report whether the list items hold paragraphs
+func isLoose(list *html.Node) bool {
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		for ch := li.FirstChild; ch != nil; ch = ch.NextSibling {
+			if ch.Type == html.ElementNode && ch.Data == "p" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// taskCheckbox return the checkbox starting the list item
+func taskCheckbox(li *html.Node) *html.Node {
+	for n := li.FirstChild; n != nil; {
+		switch {
+		case n.Type == html.TextNode && strings.TrimSpace(n.Data) == "":
+			n = n.NextSibling
+		case n.Type == html.ElementNode && n.Data == "input" && attr(n, "type") == "checkbox":
+			return n
+		case n.Type == html.ElementNode && (n.Data == "p" || n.Data == "label"):
+			n = n.FirstChild
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// listDepth return the number of lists the list is nested in
+func listDepth(n *html.Node) int {
+	var depth int
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && (p.Data == "ul" || p.Data == "ol") {
+			depth++
+		}
+	}
+	return depth
+}
+
+// list return the list of the ul or ol element
+func (c *walker) list(n *html.Node) string {
+	l := List{
+		Ordered: n.Data == "ol",
+		Start:   1,
+		Tight:   !isLoose(n),
+		Depth:   listDepth(n),
+	}
+	if prev := prevElement(n); prev != nil && prev.Data == n.Data {
+		l.Adjacent = true
+	}
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		l.Start = start
+	}
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		var task string
+		if box := taskCheckbox(li); box != nil {
+			_, checked := attrOk(box, "checked")
+			task = "[ ] "
+			if checked {
+				task = "[x] "
+			}
+			switch {
+			case c.f.TaskLists:
+			case c.f.RawHTML:
+				task = rawHTML(box) + " "
+			default:
+				task = c.r.Escape(task)
+			}
+		}
+		item := c.chunks(li, l.Tight)
+		if task != "" {
+			if len(item) == 0 {
+				item = []string{strings.TrimSpace(task)}
+			} else {
+				item[0] = task + item[0]
+			}
+		}
+		l.Items = append(l.Items, item)
+	}
+	return c.r.List(l)
+}
+
+// rawHTML return the html of the node as a html block
+func rawHTML(n *html.Node) string {
+	var b bytes.Buffer
+	if err := html.Render(&b, n); err != nil {
+		return ""
+	}
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tableRows return the rows of the table, without the rows of nested tables
+func tableRows(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	var f func(n *html.Node)
+	f = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			if ch.Type != html.ElementNode {
+				continue
+			}
+			switch ch.Data {
+			case "tr":
+				rows = append(rows, ch)
+			case "thead", "tbody", "tfoot":
+				f(ch)
+			}
+		}
+	}
+	f(table)
+	return rows
+}
+
+// isComplexTable report whether the table cells hold content pipe tables can't express
+func isComplexTable(rows []*html.Node) bool {
+	for _, tr := range rows {
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.Type != html.ElementNode {
+				continue
+			}
+			if span, _ := strconv.Atoi(attr(td, "rowspan")); span > 1 {
+				return true
+			}
+			if findElement(td, func(e *html.Node) bool {
+				switch e.Data {
+				case "table", "ul", "ol", "pre", "blockquote", "dl", "hr":
+					return true
+				}
+				return false
+			}) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasSpannedCells report whether a cell spans several rows or columns
+func hasSpannedCells(rows []*html.Node) bool {
+	for _, tr := range rows {
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.Type != html.ElementNode {
+				continue
+			}
+			rowspan, _ := strconv.Atoi(attr(td, "rowspan"))
+			colspan, _ := strconv.Atoi(attr(td, "colspan"))
+			if rowspan > 1 || colspan > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cellAlign return the left, center or right alignment of the cell, or ""
+func cellAlign(td *html.Node) string {
+	align := strings.ToLower(attr(td, "align"))
+	if align == "" {
+		style := strings.ReplaceAll(strings.ToLower(attr(td, "style")), " ", "")
+		if i := strings.Index(style, "text-align:"); i >= 0 {
+			align = strings.SplitN(style[i+len("text-align:"):], ";", 2)[0]
+		}
+	}
+	switch align {
+	case "left", "center", "right":
+		return align
+	}
+	return ""
+}
+
+// table return the table with its cells rendered
+func (c *walker) table(n *html.Node) string {
+	c.w.warn(n, WarnTable, c.h.tableWarning(n))
+	t := Table{Node: n}
+	if cap := findElement(n, func(e *html.Node) bool { return e.Data == "caption" }); cap != nil {
+		t.Caption = c.r.Paragraph(c.inlines(cap))
+	}
+	for _, tr := range tableRows(n) {
+		var row []TableCell
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.Type != html.ElementNode || td.Data != "td" && td.Data != "th" {
+				continue
+			}
+			cell := TableCell{
+				Text:    c.blocks(td, false),
+				Header:  td.Data == "th",
+				Align:   cellAlign(td),
+				Colspan: 1,
+				Rowspan: 1,
+			}
+			if span, err := strconv.Atoi(attr(td, "colspan")); err == nil && span > 1 {
+				cell.Colspan = span
+			}
+			if span, err := strconv.Atoi(attr(td, "rowspan")); err == nil && span > 1 {
+				cell.Rowspan = span
+			}
+			row = append(row, cell)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	if len(t.Rows) == 0 {
+		return ""
+	}
+	return c.r.Table(t)
+}
+
+// definitionList return the definition list in the chosen style,
+// other renderers get bold terms followed by their definitions
+func (c *walker) definitionList(n *html.Node) string {
+	var s string
+	var prev string
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type != html.ElementNode {
+			continue
+		}
+		if ch.Data == "div" {
+			// WHATWG allows dt and dd groups wrapped in div
+			s += "\n\n" + c.definitionList(ch)
+			continue
+		}
+		switch {
+		case !c.md:
+			if ch.Data == "dt" {
+				s += "\n\n" + c.r.Paragraph(c.r.Strong(strings.TrimSpace(c.inlines(ch))))
+			} else if ch.Data == "dd" {
+				s += "\n\n" + c.blocks(ch, false)
+			}
+		case c.h.dlStyle == DefinitionListHTML:
+			if ch.Data == "dt" {
+				s += "\n<dt>" + html.EscapeString(strings.TrimSpace(textContent(ch))) + "</dt>"
+			} else if ch.Data == "dd" {
+				s += "\n<dd>\n\n" + strings.Trim(c.blocks(ch, false), "\n") + "\n\n</dd>"
+			}
+		case c.h.dlStyle == DefinitionListBold:
+			if ch.Data == "dt" {
+				s += "\n\n**" + strings.Trim(c.r.Paragraph(c.inlines(ch)), "*") + "**"
+			} else if ch.Data == "dd" {
+				s += "\n\n  " + indent(strings.Trim(c.blocks(ch, false), "\n"), "  ")
+			}
+		default:
+			if ch.Data == "dt" {
+				if prev == "dt" {
+					s += "\n"
+				} else {
+					s += "\n\n"
+				}
+				s += c.r.Paragraph(c.inlines(ch))
+			} else if ch.Data == "dd" {
+				s += "\n: " + indent(strings.Trim(c.blocks(ch, false), "\n"), "    ")
+			}
+		}
+		prev = ch.Data
+	}
+	if c.md && c.h.dlStyle == DefinitionListHTML && n.Data == "dl" {
+		return "<dl>" + s + "\n</dl>"
+	}
+	return strings.Trim(s, "\n")
+}
+
+// details return the collapsible section in the chosen style,
+// other renderers get the bold summary followed by the content
+func (c *walker) details(n *html.Node) string {
+	var summary string
+	if sum := findElement(n, func(e *html.Node) bool { return e.Data == "summary" }); sum != nil {
+		summary = strings.TrimSpace(whitespace.ReplaceAllString(textContent(sum), " "))
+		c.skip[sum] = true
+	}
+	text := strings.Trim(c.blocks(n, false), "\n")
+	_, open := attrOk(n, "open")
+	switch {
+	case !c.md:
+		if summary == "" {
+			return text
+		}
+		return c.r.Paragraph(c.r.Strong(c.r.Escape(summary))) + "\n\n" + text
+	case c.h.detailsStyle == DetailsAdmonition:
+		if summary == "" {
+			summary = "Details"
+		}
+		marker := "???"
+		if open {
+			marker += "+"
+		}
+		return marker + " note \"" + summary + "\"\n\n    " + indent(text, "    ")
+	}
+	s := "<details>"
+	if open {
+		s = "<details open>"
+	}
+	if summary != "" {
+		s += "\n<summary>" + html.EscapeString(summary) + "</summary>"
+	}
+	return s + "\n\n" + text + "\n\n</details>"
+}
+
+// figure return the figure content followed by its caption
+func (c *walker) figure(n *html.Node, tight bool) string {
+	img, caption := figure(n)
+	if caption == nil {
+		return c.blocks(n, tight)
+	}
+	c.skip[caption] = true
+	text := c.r.Paragraph(c.inlines(caption))
+	if img != nil && c.h.figureStyle == FigureCaptionAlt && (attr(img, "alt") == "" || attr(img, "title") == "") {
+		c.captions[img] = strings.TrimSpace(textContent(caption))
+		text = ""
+	}
+	s := strings.Trim(c.blocks(n, tight), "\n")
+	if text != "" {
+		s += "\n\n" + c.r.Emphasis(text)
+	}
+	return c.spacedBlock(s)
+}
@@ -0,0 +1,79 @@
+package h2md
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// NewConverter create a converter with the default settings
+func NewConverter() *Converter {
+	return &Converter{replacers: make(map[string]Replacer)}
+}
+
+// ConvertString return the output of the html text with its warnings,
+// text that is not UTF-8 is decoded by its detected charset
+func (h *Converter) ConvertString(ctx context.Context, htmlText string) (string, []Warning, error) {
+	if err := h.checkInput(len(htmlText)); err != nil {
+		return "", nil, err
+	}
+	node, source, err := parseHTML(htmlText)
+	if err != nil {
+		return "", nil, err
+	}
+	return h.convert(ctx, node, source)
+}
+
+// ConvertReader return the output of the html of the reader with its warnings,
+// decoded as NewH2MDFromReader does, reading no more than the input limit allows
+func (h *Converter) ConvertReader(ctx context.Context, r io.Reader, opts *ReaderOptions) (string, []Warning, error) {
+	var o ReaderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if max := int64(h.limits.MaxInputBytes); max > 0 && (o.MaxBytes <= 0 || o.MaxBytes > max) {
+		o.MaxBytes = max
+	}
+	text, err := readHTML(r, &o)
+	if err != nil {
+		return "", nil, err
+	}
+	return h.ConvertString(ctx, text)
+}
+
+// ConvertNode return the output of the node with its warnings, the node is only read
+// and its warnings have no source position
+func (h *Converter) ConvertNode(ctx context.Context, n *html.Node) (string, []Warning, error) {
+	if n == nil {
+		return "", nil, ErrNilNode
+	}
+	return h.convert(ctx, n, "")
+}
+
+// ConvertFragment return the output of the html fragment with its warnings, parsed as the
+// content of a body element without the html, head and body elements of a document
+func (h *Converter) ConvertFragment(ctx context.Context, fragment string) (string, []Warning, error) {
+	if err := h.checkInput(len(fragment)); err != nil {
+		return "", nil, err
+	}
+	fragment, err := toUTF8(fragment)
+	if err != nil {
+		return "", nil, err
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return h.convert(ctx, root, fragment)
+}
@@ -0,0 +1,161 @@
+package h2md
+
+import (
+	"strings"
+)
+
+// defaultFlavor features of the default output, written without flavor
+var defaultFlavor = Flavor{
+	Tables:        true,
+	TaskLists:     true,
+	Strikethrough: true,
+	Footnotes:     true,
+	Math:          true,
+}
+
+// defaultMarkdown renderer of the default output, its blocks carry the line breaks
+// around them and are joined as they are
+type defaultMarkdown struct {
+	h *Converter
+}
+
+// Escape trim the text, the default output escapes nothing
+func (defaultMarkdown) Escape(text string) string {
+	return strings.TrimSpace(text)
+}
+
+// Paragraph return the inline markdown as it is
+func (defaultMarkdown) Paragraph(text string) string {
+	return text
+}
+
+// Heading return the ATX heading on its own line
+func (defaultMarkdown) Heading(level int, text, id string, classes []string) string {
+	return "\n" + strings.Repeat("#", level) + " " + text + "\n"
+}
+
+// ThematicBreak return the thematic break on its own line
+func (defaultMarkdown) ThematicBreak() string {
+	return "\n---\n"
+}
+
+// LineBreak return a line break
+func (defaultMarkdown) LineBreak() string {
+	return "\n"
+}
+
+// Emphasis return *text*
+func (defaultMarkdown) Emphasis(text string) string {
+	return "*" + text + "*"
+}
+
+// Strong return **text**
+func (defaultMarkdown) Strong(text string) string {
+	return "**" + text + "**"
+}
+
+// Strikethrough return ~~text~~
+func (defaultMarkdown) Strikethrough(text string) string {
+	return "~~" + text + "~~"
+}
+
+// Code return the code between triple backticks
+func (defaultMarkdown) Code(code string) string {
+	return "```" + code + "```"
+}
+
+// CodeBlock return the fenced code block after a blank line
+func (defaultMarkdown) CodeBlock(code, lang string) string {
+	return "\n\n```" + lang + "\n" + code + "\n```"
+}
+
+// Quote return the lines each on a new line after >
+func (m defaultMarkdown) Quote(text string) string {
+	if text == "" {
+		return ""
+	}
+	return m.quoteBlocks([]quotedBlock{{depth: 1, sep: 1, text: text}}, nil)
+}
+
+// quoteBlocks write the lines of the blocks each on a new line after a > per level, as
+// the nested quotes would be written one in another: a blockquote starting with a nested
+// one and a block ending by a line break before one leave an empty line, "" once the walk
+// is interrupted
+func (defaultMarkdown) quoteBlocks(blocks []quotedBlock, in *interrupt) string {
+	var b strings.Builder
+	var line = func(depth int, text string) {
+		b.WriteString("\n")
+		b.WriteString(strings.Repeat("> ", depth))
+		b.WriteString(text)
+	}
+	for i, block := range blocks {
+		text, from := block.text, 1
+		if i > 0 {
+			from = block.sep + 1
+			if prev := blocks[i-1]; prev.depth == block.sep && block.depth > block.sep && strings.HasSuffix(prev.text, "\n") {
+				line(block.sep, "")
+			}
+			if block.depth == block.sep {
+				text = strings.TrimPrefix(text, "\n")
+			}
+		}
+		for depth := from; depth < block.depth; depth++ {
+			line(depth, "")
+		}
+		prefix := "\n" + strings.Repeat("> ", block.depth)
+		for _, l := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+			if in.done() || in.written(b.Len()) {
+				return ""
+			}
+			b.WriteString(prefix)
+			b.WriteString(l)
+		}
+	}
+	return b.String()
+}
+
+// List return the items each on a new line after a dash, indented by a tab per level
+func (defaultMarkdown) List(l List) string {
+	var b strings.Builder
+	prefix := "\n" + strings.Repeat("\t", l.Depth) + "- "
+	for _, item := range l.Items {
+		b.WriteString(prefix)
+		for _, s := range item {
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+// Link return the markdown link
+func (defaultMarkdown) Link(text, href, title string) string {
+	return "[" + text + "](" + href + ")"
+}
+
+// Image return the markdown image
+func (defaultMarkdown) Image(alt, src, title string) string {
+	return image(alt, src, title)
+}
+
+// Table return the pipe table, the first row is the header when there are others
+func (defaultMarkdown) Table(t Table) string {
+	var b strings.Builder
+	b.WriteString(t.Caption)
+	for i, row := range t.Rows {
+		if i == 1 {
+			b.WriteString("\n| ")
+			b.WriteString(strings.Repeat("---- | ", len(t.Rows[0])))
+		}
+		b.WriteString("\n| ")
+		for _, cell := range row {
+			b.WriteString(cell.Text)
+			b.WriteString(" | ")
+		}
+	}
+	return b.String()
+}
+
+// Admonition return the callout in the chosen style
+func (m defaultMarkdown) Admonition(kind, title, text string) string {
+	return m.h.admonition(kind, title, strings.Trim(text, "\n"))
+}
@@ -0,0 +1,147 @@
+package h2md
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// EmbedProvider convert the embedded media url, ok is false when the provider does not handle it
+type EmbedProvider func(src *url.URL, n *html.Node) (md string, ok bool)
+
+// defaultEmbedProviders providers tried after the registered ones
+var defaultEmbedProviders = []EmbedProvider{
+	YouTubeEmbed,
+	VimeoEmbed,
+	BilibiliEmbed,
+	CodePenEmbed,
+}
+
+// AddEmbedProvider Register a provider for video, audio, iframe and embed elements,
+// it is tried before the previously registered and default providers
+func (h *Converter) AddEmbedProvider(p EmbedProvider) {
+	h.embedProviders = append([]EmbedProvider{p}, h.embedProviders...)
+}
+
+// SetEmbedRawHTML Keep the raw html of embeds no provider handles instead of a plain link
+func (h *Converter) SetEmbedRawHTML(allow bool) {
+	h.embedRawHTML = allow
+}
+
+// embedTitle return the title of the embed element
+func embedTitle(n *html.Node, fallback string) string {
+	for _, name := range []string{"title", "aria-label", "alt"} {
+		if v := strings.TrimSpace(attr(n, name)); v != "" {
+			return v
+		}
+	}
+	return fallback
+}
+
+// embedSrc return the media url of the element
+func embedSrc(n *html.Node) string {
+	for _, name := range []string{"src", "data-src", "data"} {
+		if v := strings.TrimSpace(attr(n, name)); v != "" {
+			return v
+		}
+	}
+	if s := findElement(n, func(e *html.Node) bool { return e.Data == "source" && attr(e, "src") != "" }); s != nil {
+		return attr(s, "src")
+	}
+	return ""
+}
+
+// embed return the markdown of video, audio, iframe, embed and object elements
+func (h *Converter) embed(n *html.Node) string {
+	src := h.resolve(embedSrc(n))
+	if src == "" {
+		return ""
+	}
+	u, err := url.Parse(src)
+	if err == nil {
+		if u.Scheme == "" && u.Host != "" {
+			u.Scheme = "https"
+			src = u.String()
+		}
+		for _, p := range h.embedProviders {
+			if md, ok := p(u, n); ok {
+				return md
+			}
+		}
+		for _, p := range defaultEmbedProviders {
+			if md, ok := p(u, n); ok {
+				return md
+			}
+		}
+	}
+	if h.embedRawHTML {
+		var b bytes.Buffer
+		if html.Render(&b, n) == nil {
+			return "\n\n" + b.String() + "\n"
+		}
+	}
+	title := embedTitle(n, src)
+	if poster := attr(n, "poster"); poster != "" {
+		return "[" + image(title, poster, "") + "](" + src + ")"
+	}
+	return "[" + title + "](" + src + ")"
+}
+
+// YouTubeEmbed YouTube thumbnail linking to the watch page
+func YouTubeEmbed(u *url.URL, n *html.Node) (string, bool) {
+	var id string
+	host := strings.TrimPrefix(u.Host, "www.")
+	switch host {
+	case "youtube.com", "m.youtube.com", "youtube-nocookie.com":
+		if strings.HasPrefix(u.Path, "/embed/") {
+			id = strings.TrimPrefix(u.Path, "/embed/")
+		} else if u.Path == "/watch" {
+			id = u.Query().Get("v")
+		}
+	case "youtu.be":
+		id = strings.TrimPrefix(u.Path, "/")
+	}
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	thumb := image(embedTitle(n, "YouTube video"), "https://img.youtube.com/vi/"+id+"/hqdefault.jpg", "")
+	return "[" + thumb + "](https://www.youtube.com/watch?v=" + id + ")", true
+}
+
+// VimeoEmbed link to the Vimeo video page
+func VimeoEmbed(u *url.URL, n *html.Node) (string, bool) {
+	if u.Host != "player.vimeo.com" || !strings.HasPrefix(u.Path, "/video/") {
+		return "", false
+	}
+	id := strings.TrimPrefix(u.Path, "/video/")
+	return "[" + embedTitle(n, "Vimeo video") + "](https://vimeo.com/" + id + ")", true
+}
+
+// BilibiliEmbed link to the Bilibili video page
+func BilibiliEmbed(u *url.URL, n *html.Node) (string, bool) {
+	if u.Host != "player.bilibili.com" {
+		return "", false
+	}
+	q := u.Query()
+	var page string
+	if bvid := q.Get("bvid"); bvid != "" {
+		page = "https://www.bilibili.com/video/" + bvid
+	} else if aid := q.Get("aid"); aid != "" {
+		page = "https://www.bilibili.com/video/av" + aid
+	} else {
+		return "", false
+	}
+	return "[" + embedTitle(n, "Bilibili video") + "](" + page + ")", true
+}
+
+// CodePenEmbed link to the CodePen pen
+func CodePenEmbed(u *url.URL, n *html.Node) (string, bool) {
+	if u.Host != "codepen.io" || !strings.Contains(u.Path, "/embed/") {
+		return "", false
+	}
+	page := "https://codepen.io" + strings.Replace(u.Path, "/embed/preview/", "/pen/", 1)
+	page = strings.Replace(page, "/embed/", "/pen/", 1)
+	return "[" + embedTitle(n, "CodePen") + "](" + page + ")", true
+}
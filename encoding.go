@@ -0,0 +1,223 @@
+package h2md
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// metaCharset charset of <meta charset> and <meta http-equiv="Content-Type" content="...; charset=">
+var metaCharset = regexp.MustCompile(`(?i)<meta\s[^>]*?charset\s*=\s*["']?\s*([\w.:-]+)`)
+
+// metaScanBytes bytes scanned for the meta charset
+const metaScanBytes = 4096
+
+// sniffBytes bytes decoded by the candidates when no charset is declared
+const sniffBytes = 16 << 10
+
+// sniffCandidates encodings tried on undeclared non UTF-8 html, ties go to the first
+// so Chinese wins over Korean, whose pages declare their charset more often
+var sniffCandidates = []struct {
+	name string
+	enc  encoding.Encoding
+}{
+	{"gb18030", simplifiedchinese.GB18030},
+	{"big5", traditionalchinese.Big5},
+	{"shift_jis", japanese.ShiftJIS},
+	{"euc-jp", japanese.EUCJP},
+	{"euc-kr", korean.EUCKR},
+	{"windows-1252", charmap.Windows1252},
+}
+
+// ReaderOptions settings of NewH2MDFromReader
+type ReaderOptions struct {
+	// Charset encoding of the html, e.g. gbk or big5, it overrides the detected one
+	Charset string
+	// ContentType Content-Type header the html came with, its charset is used when there is no BOM
+	ContentType string
+	// MaxBytes largest html read, ErrInputTooLarge beyond it, 0 is no limit
+	MaxBytes int64
+}
+
+// NewH2MDFromReader create H2MD with the html of the reader, decoded by the override charset,
+// or the one of its BOM, Content-Type, meta tags or content
+func NewH2MDFromReader(r io.Reader, opts *ReaderOptions) (*H2MD, error) {
+	text, err := readHTML(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewH2MD(text)
+}
+
+// readHTML return the html of the reader decoded as NewH2MDFromReader does
+func readHTML(r io.Reader, opts *ReaderOptions) (string, error) {
+	if opts == nil {
+		opts = &ReaderOptions{}
+	}
+	if opts.MaxBytes > 0 {
+		r = io.LimitReader(r, opts.MaxBytes+1)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if opts.MaxBytes > 0 && int64(len(b)) > opts.MaxBytes {
+		return "", fmt.Errorf("%w: the limit is %d bytes", ErrInputTooLarge, opts.MaxBytes)
+	}
+	name := opts.Charset
+	if name == "" {
+		name = DetectCharset(b, opts.ContentType)
+	}
+	return decodeHTML(b, name)
+}
+
+// DetectCharset return the encoding of the html from its BOM, the charset of the Content-Type,
+// UTF-8 validity, its meta tags, or the content when none is declared
+func DetectCharset(b []byte, contentType string) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if _, name := charset.Lookup(params["charset"]); name != "" {
+			return name
+		}
+	}
+	// a document decoded before keeps the meta of its old encoding, valid UTF-8 is taken as is
+	if utf8.Valid(b) {
+		return "utf-8"
+	}
+	head := b
+	if len(head) > metaScanBytes {
+		head = head[:metaScanBytes]
+	}
+	if m := metaCharset.FindSubmatch(head); m != nil {
+		switch _, name := charset.Lookup(string(m[1])); name {
+		case "":
+		case "utf-16le", "utf-16be":
+			// a meta read from the bytes can't be in UTF-16, the page is taken as UTF-8
+			return "utf-8"
+		default:
+			return name
+		}
+	}
+	// a UTF-8 page with a few stray bytes stays UTF-8
+	if mostlyUTF8(b) {
+		return "utf-8"
+	}
+	return sniffCharset(b)
+}
+
+// mostlyUTF8 report whether the valid multi-byte sequences are at least twice as many
+// as the invalid bytes, which legacy encodings rarely produce
+func mostlyUTF8(b []byte) bool {
+	var valid, invalid int
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+		case size > 1:
+			valid++
+		}
+		b = b[size:]
+	}
+	return valid > 0 && valid >= 2*invalid
+}
+
+// sniffCharset return the candidate encoding whose decoding of the start of the content scores best
+func sniffCharset(b []byte) string {
+	if len(b) > sniffBytes {
+		b = b[:sniffBytes]
+	}
+	best, bestScore := "", 0
+	for _, c := range sniffCandidates {
+		text, err := c.enc.NewDecoder().Bytes(b)
+		if err != nil {
+			continue
+		}
+		if score := sniffScore(string(text)); best == "" || score > bestScore {
+			best, bestScore = c.name, score
+		}
+	}
+	return best
+}
+
+// sniffScore score the decoded text, frequent ideographs, kana and hangul count for it,
+// invalid sequences, private use and unrelated scripts against it
+func sniffScore(s string) int {
+	score := 0
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+		case r == utf8.RuneError:
+			score -= 10
+		case unicode.Is(unicode.Han, r):
+			score += commonHan(r)
+		case r >= 0xFF61 && r <= 0xFF9F:
+			// half width katakana, what single bytes of double byte encodings become in Shift_JIS
+			score--
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			score += 3
+		case r >= 0xE000 && r <= 0xF8FF:
+			score -= 5
+		case r < 0x370 && unicode.IsLetter(r):
+			score++
+		default:
+			score--
+		}
+	}
+	return score
+}
+
+// commonHan return 3 for the frequent ideographs of GB2312 level 1 and Big5, 1 for the others
+func commonHan(r rune) int {
+	if b, err := simplifiedchinese.GBK.NewEncoder().String(string(r)); err == nil && len(b) == 2 && b[0] >= 0xB0 && b[0] <= 0xD7 {
+		return 3
+	}
+	if b, err := traditionalchinese.Big5.NewEncoder().String(string(r)); err == nil && len(b) == 2 && b[0] >= 0xA4 && b[0] <= 0xC6 {
+		return 3
+	}
+	return 1
+}
+
+// toUTF8 return the html text, decoded by its detected charset when it is not UTF-8
+func toUTF8(htmlText string) (string, error) {
+	if utf8.ValidString(htmlText) {
+		return htmlText, nil
+	}
+	return decodeHTML([]byte(htmlText), DetectCharset([]byte(htmlText), ""))
+}
+
+// decodeHTML return the html decoded from the named encoding without its BOM
+func decodeHTML(b []byte, name string) (string, error) {
+	enc, canonical := charset.Lookup(name)
+	if enc == nil {
+		return "", fmt.Errorf("h2md: unknown charset %q", name)
+	}
+	if canonical == "utf-8" {
+		return strings.TrimPrefix(string(b), "\ufeff"), nil
+	}
+	text, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(string(text), "\ufeff"), nil
+}
@@ -0,0 +1,97 @@
+package h2md
+
+// Flavor markdown dialect written by Text, the features it lacks fall back to
+// raw html when allowed or to plain text
+type Flavor struct {
+	// Name of the dialect
+	Name string
+	// Tables write pipe tables
+	Tables bool
+	// TaskLists write "- [x]" list items
+	TaskLists bool
+	// Strikethrough write ~~text~~
+	Strikethrough bool
+	// Autolinks bare urls become links, so urls in text are escaped
+	Autolinks bool
+	// Footnotes write [^1] references and definitions
+	Footnotes bool
+	// Math write $tex$ and $$tex$$
+	Math bool
+	// RawHTML write raw html for elements without markdown syntax
+	RawHTML bool
+	// Subscript write ~sub~ and ^sup^
+	Subscript bool
+	// Attributes write {#id .class} after headings
+	Attributes bool
+	// FencedDivs write divs with an id or class as ::: fenced divs
+	FencedDivs bool
+	// GridTables write grid tables for tables whose cells hold blocks
+	GridTables bool
+
+	DefinitionList DefinitionListStyle
+	Details        DetailsStyle
+	Admonition     AdmonitionStyle
+	Figure         FigureStyle
+	ImageAttr      ImageAttrStyle
+}
+
+// GFM GitHub Flavored Markdown
+var GFM = Flavor{
+	Name:           "gfm",
+	Tables:         true,
+	TaskLists:      true,
+	Strikethrough:  true,
+	Autolinks:      true,
+	Footnotes:      true,
+	Math:           true,
+	RawHTML:        true,
+	DefinitionList: DefinitionListHTML,
+	Details:        DetailsHTML,
+	Admonition:     AdmonitionGitHub,
+	Figure:         FigureCaption,
+	ImageAttr:      ImageAttrHTML,
+}
+
+// CommonMark strict CommonMark without extensions, tables and strikethrough
+// are written as raw html, admonitions as plain blockquotes
+var CommonMark = Flavor{
+	Name:           "commonmark",
+	RawHTML:        true,
+	DefinitionList: DefinitionListHTML,
+	Details:        DetailsHTML,
+	Admonition:     AdmonitionQuote,
+	Figure:         FigureCaption,
+	ImageAttr:      ImageAttrHTML,
+}
+
+// Pandoc Pandoc's markdown
+var Pandoc = Flavor{
+	Name:           "pandoc",
+	Tables:         true,
+	TaskLists:      true,
+	Strikethrough:  true,
+	Footnotes:      true,
+	Math:           true,
+	RawHTML:        true,
+	Subscript:      true,
+	Attributes:     true,
+	FencedDivs:     true,
+	GridTables:     true,
+	DefinitionList: DefinitionListExtra,
+	Details:        DetailsHTML,
+	Admonition:     AdmonitionPandoc,
+	Figure:         FigureCaptionAlt,
+	ImageAttr:      ImageAttrBraces,
+}
+
+// SetFlavor Write markdown of the flavor instead of the default output,
+// the styles of the flavor can be changed by the setters called afterwards
+func (h *Converter) SetFlavor(f Flavor) {
+	h.flavor = &f
+	h.renderer = nil
+	h.dlStyle = f.DefinitionList
+	h.detailsStyle = f.Details
+	h.admonitionStyle = f.Admonition
+	h.figureStyle = f.Figure
+	h.imageAttrStyle = f.ImageAttr
+}
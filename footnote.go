@@ -0,0 +1,149 @@
+package h2md
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// footnote a footnote definition found in the document
+type footnote struct {
+	label string
+	node  *html.Node
+}
+
+// footnotes footnote definitions and references collected before conversion
+type footnotes struct {
+	defs       map[string]*footnote
+	list       []*footnote
+	containers map[*html.Node]bool
+	backlinks  map[string]bool
+}
+
+// isFootnoteContainer report whether the element holds the footnote definitions list,
+// as generated by Pandoc, kramdown, goldmark and Wikipedia
+func isFootnoteContainer(n *html.Node) bool {
+	switch n.Data {
+	case "section", "div", "ol", "aside":
+		return hasClass(n, "footnotes") || hasClass(n, "footnote-list") ||
+			hasClass(n, "references") || attr(n, "role") == "doc-endnotes"
+	}
+	return false
+}
+
+// collectFootnotes find footnote definitions in the tree
+func collectFootnotes(root *html.Node) *footnotes {
+	fs := &footnotes{
+		defs:       make(map[string]*footnote),
+		containers: make(map[*html.Node]bool),
+		backlinks:  make(map[string]bool),
+	}
+	var add = func(id string, n *html.Node) {
+		if id == "" || fs.defs[id] != nil {
+			return
+		}
+		fn := &footnote{label: strconv.Itoa(len(fs.list) + 1), node: n}
+		fs.defs[id] = fn
+		fs.list = append(fs.list, fn)
+	}
+	var items func(n *html.Node)
+	items = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.Data == "li" {
+				add(attr(c, "id"), c)
+				continue
+			}
+			items(c)
+		}
+	}
+	var f func(n *html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			// a container holding no definitions, e.g. a list of general references, is kept
+			if before := len(fs.list); isFootnoteContainer(n) {
+				if items(n); len(fs.list) > before {
+					fs.containers[n] = true
+					return
+				}
+			}
+			// Substack: <div class="footnote"><a class="footnote-number" id="footnote-1"> <div class="footnote-content">
+			if n.Data == "div" && hasClass(n, "footnote") {
+				var id string
+				var content *html.Node
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type != html.ElementNode {
+						continue
+					}
+					if c.Data == "a" && hasClass(c, "footnote-number") {
+						id = attr(c, "id")
+						fs.backlinks[strings.TrimPrefix(attr(c, "href"), "#")] = true
+					}
+					if hasClass(c, "footnote-content") {
+						content = c
+					}
+				}
+				if id != "" && content != nil {
+					fs.containers[n] = true
+					add(id, content)
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(root)
+	if len(fs.list) == 0 {
+		return fs
+	}
+	// remember the ids of the references, so the back links in definitions can be dropped
+	var refs func(n *html.Node)
+	refs = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" && fs.ref(n) != nil {
+			fs.backlinks[attr(n, "id")] = true
+			if n.Parent != nil && n.Parent.Data == "sup" {
+				fs.backlinks[attr(n.Parent, "id")] = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			refs(c)
+		}
+	}
+	refs(root)
+	delete(fs.backlinks, "")
+	return fs
+}
+
+// ref return the footnote referenced by the link
+func (fs *footnotes) ref(a *html.Node) *footnote {
+	href := attr(a, "href")
+	if !strings.HasPrefix(href, "#") {
+		return nil
+	}
+	return fs.defs[href[1:]]
+}
+
+// supRef return the footnote referenced by the link inside a sup element
+func (fs *footnotes) supRef(sup *html.Node) *footnote {
+	for c := sup.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "a" {
+			return fs.ref(c)
+		}
+	}
+	return nil
+}
+
+// isBacklink report whether the element is a link back to the footnote reference
+func (fs *footnotes) isBacklink(n *html.Node) bool {
+	if hasClass(n, "mw-cite-backlink") || hasClass(n, "footnote-back") ||
+		hasClass(n, "reversefootnote") || attr(n, "role") == "doc-backlink" {
+		return true
+	}
+	href := attr(n, "href")
+	return n.Data == "a" && strings.HasPrefix(href, "#") && fs.backlinks[href[1:]]
+}
@@ -1,5 +1,9 @@
 module github.com/diiyw/h2md
 
-go 1.13
+go 1.19
 
-require golang.org/x/net v0.7.0
+require (
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/net v0.7.0
+	golang.org/x/text v0.7.0
+)
@@ -1,70 +1,149 @@
 package h2md
 
 import (
-	"bufio"
-	"bytes"
 	"golang.org/x/net/html"
-	"io"
-	"strconv"
+	"net/url"
 	"strings"
 )
 
-// H2MD H2MD struct
+// H2MD H2MD struct, a document with the converter of its settings,
+// a nil Converter converts with the default settings
 type H2MD struct {
 	*html.Node
-	ulN          int
-	blockquoteN  int
-	tdN          int
-	tableSpliced bool
-	skipNewline  bool
+	*Converter
+	source string
+	// size bytes of the html the tree was parsed from, kept when the source is not
+	size int
+}
+
+// Converter options and rules of conversions, independent of the documents it converts.
+// Conversions only read the tree and keep their state in the call, so a converter may
+// convert from many goroutines once its settings are done. The zero value is ready to use
+type Converter struct {
 	replacers    map[string]Replacer
+	limits       Limits
+	baseURL      *url.URL
+	flavor       *Flavor
+	renderer     Renderer
+	dlStyle      DefinitionListStyle
+	detailsStyle DetailsStyle
+	figureStyle  FigureStyle
+
+	imageMaxWidth  int
+	imageAttrStyle ImageAttrStyle
+
+	embedProviders []EmbedProvider
+	embedRawHTML   bool
+
+	admonitionStyle   AdmonitionStyle
+	admonitionClasses map[string]string
 }
 
 type Replacer func(val string, n *html.Node) string
 
-// NewH2MD create H2MD with html text
+// DefinitionListStyle how dl elements are written
+type DefinitionListStyle int
+
+const (
+	// DefinitionListExtra PHP Markdown Extra / Pandoc syntax: "Term\n: Definition"
+	DefinitionListExtra DefinitionListStyle = iota
+	// DefinitionListBold CommonMark fallback: bold term followed by indented paragraph
+	DefinitionListBold
+	// DefinitionListHTML dl, dt and dd tags around the converted definitions
+	DefinitionListHTML
+)
+
+// DetailsStyle how details elements are written
+type DetailsStyle int
+
+const (
+	// DetailsHTML keep the details and summary tags around the converted content
+	DetailsHTML DetailsStyle = iota
+	// DetailsAdmonition collapsible admonition syntax: ??? note "Summary"
+	DetailsAdmonition
+)
+
+// NewH2MD create H2MD with html text, text that is not UTF-8 is decoded by its detected charset
 func NewH2MD(htmlText string) (*H2MD, error) {
-	node, err := html.Parse(strings.NewReader(htmlText))
+	node, source, err := parseHTML(htmlText)
 	if err == nil {
 		return &H2MD{
-			Node:         node,
-			ulN:          -1,
-			blockquoteN:  0,
-			tdN:          0,
-			tableSpliced: false,
-			skipNewline:  true,
-			replacers:    make(map[string]Replacer),
+			Node:      node,
+			Converter: NewConverter(),
+			source:    source,
+			size:      len(htmlText),
 		}, nil
 	}
 	return nil, err
 }
 
-//NewH2MDFromNode create H2MD with html node
+// parseHTML return the tree of the html text and the UTF-8 source it was parsed from
+func parseHTML(htmlText string) (*html.Node, string, error) {
+	htmlText, err := toUTF8(htmlText)
+	if err != nil {
+		return nil, "", err
+	}
+	node, err := html.Parse(strings.NewReader(htmlText))
+	if err != nil {
+		return nil, "", err
+	}
+	return node, htmlText, nil
+}
+
+// NewH2MDFromNode create H2MD with html node, the warnings of the node have no source position
 func NewH2MDFromNode(node *html.Node) (*H2MD, error) {
+	if node == nil {
+		return nil, ErrNilNode
+	}
 	return &H2MD{
-		Node:         node,
-		ulN:          -1,
-		blockquoteN:  0,
-		tdN:          0,
-		tableSpliced: false,
-		skipNewline:  true,
-		replacers:    make(map[string]Replacer),
+		Node:      node,
+		Converter: NewConverter(),
 	}, nil
 }
 
 // Replace Replace element attribute value
-func (h *H2MD) Replace(attr string, r Replacer) {
+func (h *Converter) Replace(attr string, r Replacer) {
+	if h.replacers == nil {
+		h.replacers = make(map[string]Replacer)
+	}
 	h.replacers[attr] = r
 }
 
+// SetDefinitionListStyle Set how definition lists are written
+func (h *Converter) SetDefinitionListStyle(style DefinitionListStyle) {
+	h.dlStyle = style
+}
+
+// SetDetailsStyle Set how collapsible details sections are written
+func (h *Converter) SetDetailsStyle(style DetailsStyle) {
+	h.detailsStyle = style
+}
+
 // Attr Return the element attribute
 func (h *H2MD) Attr(name string, n *html.Node) string {
+	return h.converter().Attr(name, n)
+}
+
+// converter return the converter of the settings, the default one when it is nil
+func (h *H2MD) converter() *Converter {
+	if h.Converter == nil {
+		return &Converter{}
+	}
+	return h.Converter
+}
+
+// Attr Return the element attribute, href and src resolved and replaced
+func (h *Converter) Attr(name string, n *html.Node) string {
 	for _, attr := range n.Attr {
 		if name == attr.Key {
+			val := attr.Val
+			if name == "href" || name == "src" {
+				val = h.resolve(val)
+			}
 			if r, ok := h.replacers[name]; ok {
-				return r(attr.Val, n)
+				return r(val, n)
 			}
-			return attr.Val
+			return val
 		}
 	}
 	return ""
@@ -72,156 +151,52 @@ func (h *H2MD) Attr(name string, n *html.Node) string {
 
 // Text return the markdown content
 func (h *H2MD) Text() string {
-	var buf bytes.Buffer
-
-	var f func(*html.Node)
-
-	f = func(n *html.Node) {
+	return h.converter().render(h.Node, nil, nil)
+}
 
-		var parse = func(tag string, single bool) {
-			buf.WriteString(tag)
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				f(c)
-			}
-			if n.LastChild != nil {
-				n = n.LastChild.NextSibling
-			}
-			if !single {
-				buf.WriteString(tag)
-			}
-		}
-		if n.Type == html.TextNode {
-			if h.skipNewline {
-				n.Data = strings.TrimSpace(n.Data)
-			}
-			buf.WriteString(n.Data)
-		}
-		if n.Type == html.ElementNode {
-			switch n.Data {
-			case "hr":
-				buf.WriteString("\n---\n")
-			case "a":
-				if c := n.FirstChild; c != nil {
-					buf.WriteString("[" + c.Data + "](" + h.Attr("href", n) + ")")
-					n = c
-				}
-			case "img":
-				if n.Parent != nil && n.Parent.Data == "p" {
-					buf.WriteString("\n")
-				}
-				buf.WriteString("![" + h.Attr("alt", n) + "](" + h.Attr("src", n) + ")")
-				if n.Parent != nil && n.Parent.Data == "p" {
-					buf.WriteString("\n")
-				}
-			case "del":
-				parse("~~", false)
-			case "i":
-				parse("*", false)
-			case "strong", "b":
-				parse("**", false)
-			case "h1", "h2", "h3", "h4", "h5", "h6":
-				buf.WriteString("\n")
-				j, _ := strconv.Atoi(n.Data[1:])
-				h.skipNewline = true
-				parse(strings.Repeat("#", j)+" ", true)
-				buf.WriteString("\n")
-			case "code":
-				h.skipNewline = false
-				lang := h.Attr("class", n)
-				var newline = ""
-				if n.Parent != nil && n.Parent.Data == "pre" {
-					buf.WriteString("\n")
-					if lang == "" {
-						lang = h.Attr("class", n.Parent)
-					}
-					newline = "\n"
-				}
-				lang = strings.ReplaceAll(lang, "hljs", "")
-				lang = strings.ReplaceAll(lang, "prism", "")
-				lang = strings.ReplaceAll(lang, "highlight", "")
-				lang = strings.ReplaceAll(lang, "highlight-source-", "")
-				lang = strings.ReplaceAll(lang, "language-", "")
-				lang = strings.TrimSpace(lang)
-				if lang != "" {
-					lang = strings.Split(lang, " ")[0]
-					newline = "\n"
-				}
-				buf.WriteString(newline)
-				buf.WriteString("```")
-				buf.WriteString(lang)
-				buf.WriteString(newline)
-				parse("", true)
-				buf.WriteString(newline)
-				buf.WriteString("```")
-			case "ul", "ol":
-				h.ulN++
-				parse("", true)
-				h.ulN--
-			case "li":
-				h.skipNewline = true
-				buf.WriteString("\n")
-				if h.ulN > 0 {
-					buf.WriteString(strings.Repeat("	", h.ulN))
-				}
-				parse("- ", true)
-			case "blockquote":
-				h.skipNewline = true
-				h.blockquoteN++
-				var prevBuf bytes.Buffer
-				prevBuf.Write(buf.Bytes())
-				buf.Reset()
-				parse("", true)
-				h.blockquoteN--
-				br := bufio.NewReader(&buf)
-				for {
-					a, _, c := br.ReadLine()
-					if c == io.EOF {
-						break
-					}
-					prevBuf.WriteString("\n> ")
-					prevBuf.Write(a)
-				}
-				buf.Reset()
-				buf.Write(prevBuf.Bytes())
-				h.skipNewline = false
-			case "tr":
-				h.skipNewline = true
-				if h.tdN > 0 && !h.tableSpliced {
-					buf.WriteString("\n| ")
-					buf.WriteString(strings.Repeat("---- | ", h.tdN))
-					h.tdN = 0
-					h.tableSpliced = true
-				}
-				h.skipNewline = true
-				buf.WriteString("\n| ")
-			case "td", "th":
-				h.skipNewline = false
-				parse("", true)
-				buf.WriteString(" | ")
-				h.skipNewline = true
-				h.tdN++
-			case "pre":
-				h.skipNewline = false
-				if n.FirstChild != nil && n.FirstChild.Data != "code" {
-					parse("\n```\n", false)
-				}
-				h.skipNewline = true
-			case "p":
-				if !h.skipNewline {
-					buf.WriteString("\n")
-				}
-			case "br":
-				buf.WriteString("\n")
-			}
+// indent indent every line except the first, blank lines are kept empty
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			lines[i] = prefix + lines[i]
 		}
-		if n != nil && n.FirstChild != nil {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				f(c)
-			}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prevElement return the previous sibling element
+func prevElement(n *html.Node) *html.Node {
+	for c := n.PrevSibling; c != nil; c = c.PrevSibling {
+		if c.Type == html.ElementNode {
+			return c
 		}
 	}
+	return nil
+}
+
+// attr return the raw attribute value without replacers
+func attr(n *html.Node, name string) string {
+	val, _ := attrOk(n, name)
+	return val
+}
 
-	f(h.Node)
+// attrOk return the raw attribute value and whether the attribute is present
+func attrOk(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
 
-	return buf.String()
+// hasClass report whether the element has the css class
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
 }
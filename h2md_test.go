@@ -1,8 +1,27 @@
 package h2md
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
 )
 
 func TestNewH2MD(t *testing.T) {
@@ -34,6 +53,8 @@ func TestNewH2MD(t *testing.T) {
 		{"<pre class=\"hljs javascript\"><code>code</code></pre>", "\n\n```javascript\ncode\n```"},
 		{"<blockquote>blockquote</blockquote>", "\n> blockquote"},
 		{"<blockquote>blockquote<blockquote>sub blockquote</blockquote></blockquote>", "\n> blockquote\n> > sub blockquote"},
+		{"<blockquote><h2>h</h2><blockquote>q</blockquote><ul><li>l</li></ul></blockquote>", "\n> \n> ## h\n> \n> > q\n> - l"},
+		{"<blockquote><blockquote><p>a</p></blockquote><blockquote><p>b</p></blockquote><p>c</p></blockquote>", "\n> \n> > a\n> > b\n> c"},
 
 		{"<a href=\"xxx.com\">link</a>", "[link](xxx.com)"},
 		{"<img src=\"xxx.jpg\" alt=\"image\"/>", "![image](xxx.jpg)"},
@@ -44,6 +65,9 @@ func TestNewH2MD(t *testing.T) {
 			"<table><tr><th>table header</th><th>table header 1</th></tr><tr><td>table data</td><td>table data 1</td></tr></table>",
 			"\n| table header | table header 1 | \n| ---- | ---- | \n| table data | table data 1 | ",
 		},
+		{"<pre><code>code</code></pre><p>after</p>", "\n\n```\ncode\n```\nafter"},
+		{"<p>one</p><p>two</p>", "one\ntwo"},
+		{"<em>em</em>", "*em*"},
 	}
 
 	for _, htmlText := range htmlTexts {
@@ -505,4 +529,1177 @@ func TestNewH2MDPrism(t *testing.T) {
 		t.Error(err)
 	}
 	fmt.Println(h.Text())
-}
\ No newline at end of file
+}
+func TestFootnotes(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{
+			`<p>Text<a href="#fn1" class="footnote-ref" id="fnref1" role="doc-noteref"><sup>1</sup></a></p><section class="footnotes" role="doc-endnotes"><hr /><ol><li id="fn1" role="doc-endnote"><p>The note.<a href="#fnref1" class="footnote-back" role="doc-backlink">↩︎</a></p></li></ol></section>`,
+			"Text[^1]\n\n[^1]: The note.",
+		},
+		{
+			`<p>Text<sup id="cite_ref-1" class="reference"><a href="#cite_note-1">[1]</a></sup></p><ol class="references"><li id="cite_note-1"><span class="mw-cite-backlink"><b><a href="#cite_ref-1">^</a></b></span><span class="reference-text">Smith</span></li></ol>`,
+			"Text[^1]\n\n[^1]: Smith",
+		},
+		{
+			`<p>Text<a class="footnote-anchor" href="#footnote-1" id="footnote-anchor-1">1</a></p><div class="footnote"><a class="footnote-number" href="#footnote-anchor-1" id="footnote-1">1</a><div class="footnote-content"><p>Substack</p></div></div>`,
+			"Text[^1]\n\n[^1]: Substack",
+		},
+		{
+			`<p>A<sup id="fnref:1"><a href="#fn:1" class="footnote">1</a></sup>B<sup id="fnref:2"><a href="#fn:2" class="footnote">2</a></sup></p><div class="footnotes"><ol><li id="fn:1"><p>One<a href="#fnref:1" class="reversefootnote">&#8617;</a></p></li><li id="fn:2"><p>Two</p></li></ol></div>`,
+			"A[^1]B[^2]\n\n[^1]: One\n\n[^2]: Two",
+		},
+		{`<p>x<sup>2</sup></p>`, "x2"},
+		{`<div class="references"><p>Some general references</p></div>`, "Some general references"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+
+	// a container without definitions is converted as any other element
+	for _, test := range []struct {
+		flavor Flavor
+		expect string
+	}{
+		{GFM, "Some general references"},
+		{Pandoc, "::: {.references}\nSome general references\n:::"},
+	} {
+		h, _ := NewH2MD(`<div class="references"><p>Some general references</p></div>`)
+		h.SetFlavor(test.flavor)
+		if text := h.Text(); text != test.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", test.expect, text)
+		}
+	}
+}
+
+func TestDefinitionList(t *testing.T) {
+	dl := `<dl><dt>Apple</dt><dd>Red fruit</dd><dd>Company</dd><dt>Go</dt><dt>Golang</dt><dd><p>Language</p><ul><li>fast</li></ul></dd></dl>`
+	htmlTexts := []struct {
+		style  DefinitionListStyle
+		expect string
+	}{
+		{DefinitionListExtra, "\n\nApple\n: Red fruit\n: Company\n\nGo\nGolang\n: Language\n    - fast\n"},
+		{DefinitionListBold, "\n\n**Apple**\n\n  Red fruit\n\n  Company\n\n**Go**\n\n**Golang**\n\n  Language\n  - fast\n"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(dl)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetDefinitionListStyle(htmlText.style)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestDetails(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		style  DetailsStyle
+		expect string
+	}{
+		{
+			`<details><summary>More</summary><p>Hidden</p><ul><li>a</li></ul></details>`,
+			DetailsHTML,
+			"\n\n<details>\n<summary>More</summary>\n\nHidden\n- a\n\n</details>\n",
+		},
+		{
+			`<details><summary>More</summary><p>Hidden</p><ul><li>a</li></ul></details>`,
+			DetailsAdmonition,
+			"\n\n??? note \"More\"\n\n    Hidden\n    - a\n",
+		},
+		{`<details open><p>Hidden</p></details>`, DetailsHTML, "\n\n<details open>\n\nHidden\n\n</details>\n"},
+		{`<details open><p>Hidden</p></details>`, DetailsAdmonition, "\n\n???+ note \"Details\"\n\n    Hidden\n"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetDetailsStyle(htmlText.style)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestAdmonition(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		style  AdmonitionStyle
+		expect string
+	}{
+		{`<div class="admonition warning"><p class="admonition-title">Careful</p><p>Hot</p></div>`, AdmonitionGitHub, "\n\n> [!WARNING]\n> **Careful**\n> Hot\n"},
+		{`<div class="admonition warning"><p class="admonition-title">Careful</p><p>Hot</p></div>`, AdmonitionMkDocs, "\n\n!!! warning \"Careful\"\n\n    Hot\n"},
+		{`<div class="admonition warning"><p class="admonition-title">Careful</p><p>Hot</p></div>`, AdmonitionDocusaurus, "\n\n:::warning[Careful]\n\nHot\n\n:::\n"},
+		{`<div class="markdown-alert markdown-alert-note"><p class="markdown-alert-title"><svg></svg>Note</p><p>Useful</p></div>`, AdmonitionGitHub, "\n\n> [!NOTE]\n> Useful\n"},
+		{`<div class="theme-admonition theme-admonition-danger admonition_LlT9"><div class="admonitionHeading_tbUL">danger</div><div class="admonitionContent_BuS1"><p>Boom</p></div></div>`, AdmonitionGitHub, "\n\n> [!CAUTION]\n> Boom\n"},
+		{`<div class="box-tip"><p>Custom</p></div>`, AdmonitionMkDocs, "\n\n!!! tip\n\n    Custom\n"},
+		{`<div class="box"><p>Plain</p></div>`, AdmonitionGitHub, "Plain"},
+		{`<div class="admonition warning"><p class="admonition-title">Careful</p><p>Hot</p></div>`, AdmonitionQuote, "\n\n> **Careful**\n> Hot\n"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetAdmonitionStyle(htmlText.style)
+		h.SetAdmonitionClass("box-tip", "tip")
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+
+	// empty and unknown kinds are rejected, the class stays a plain div
+	for _, kind := range []string{"", "box"} {
+		h, _ := NewH2MD(`<div class="box"><p>Plain</p></div>`)
+		h.SetFlavor(CommonMark)
+		if err := h.SetAdmonitionClass("box", kind); err == nil {
+			t.Errorf("Expect an error for the kind \"%s\"", kind)
+		}
+		if text := h.Text(); text != "Plain" {
+			t.Errorf("Expect \"%s\" but got \"%s\"", "Plain", text)
+		}
+	}
+	h := NewConverter()
+	if err := h.SetAdmonitionClass("box", "Bug"); err != nil {
+		t.Error(err)
+	}
+	if text, _, _ := h.ConvertString(context.Background(), `<div class="box"><p>x</p></div>`); text != "\n\n> [!CAUTION]\n> x\n" {
+		t.Errorf("Expect \"%s\" but got \"%s\"", "\n\n> [!CAUTION]\n> x\n", text)
+	}
+}
+
+func TestMath(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<span class="katex"><span class="katex-mathml"><math><semantics><mi>r</mi><annotation encoding="application/x-tex">\pi r^2</annotation></semantics></math></span><span class="katex-html">πr2</span></span>`, `$\pi r^2$`},
+		{`<span class="katex-display"><span class="katex"><math><semantics><mi>E</mi><annotation encoding="application/x-tex">E=mc^2</annotation></semantics></math></span></span>`, "\n\n$$\nE=mc^2\n$$\n"},
+		{`<span class="MathJax_Preview">x</span><span class="MathJax">x</span><script type="math/tex">x^2</script>`, `$x^2$`},
+		{`<script type="math/tex; mode=display">\int_0^1 f</script>`, "\n\n$$\n\\int_0^1 f\n$$\n"},
+		{`<math display="block"><mfrac><mrow><mo>-</mo><mi>b</mi><mo>±</mo><msqrt><msup><mi>b</mi><mn>2</mn></msup><mo>−</mo><mn>4</mn><mi>a</mi><mi>c</mi></msqrt></mrow><mrow><mn>2</mn><mi>a</mi></mrow></mfrac></math>`, "\n\n$$\n\\frac{-b\\pm\\sqrt{b^2-4ac}}{2a}\n$$\n"},
+		{`<math><munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><msub><mi>x</mi><mi>i</mi></msub><mi>sin</mi><mo>⁡</mo><mi>α</mi></math>`, `$\sum_{i=1}^nx_i\sin\alpha$`},
+		{`<mjx-container class="MathJax" display="true"><mjx-assistive-mml><math><mover><mi>v</mi><mo>→</mo></mover></math></mjx-assistive-mml></mjx-container>`, "\n\n$$\n\\vec{v}\n$$\n"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestFigure(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		style  FigureStyle
+		expect string
+	}{
+		{`<img src="a.png" alt="A" title="T">`, FigureCaption, `![A](a.png "T")`},
+		{`<figure><img src="a.png" alt="A"><figcaption>Cap</figcaption></figure>`, FigureCaption, "\n\n![A](a.png)\n\n*Cap*\n"},
+		{`<figure><img src="a.png" alt="A"><figcaption>Cap</figcaption></figure>`, FigureCaptionAlt, "\n\n![A](a.png \"Cap\")\n"},
+		{`<figure><a href="x"><img src="a.png"></a><figcaption>Cap</figcaption></figure>`, FigureCaptionAlt, "\n\n[![Cap](a.png)](x)\n"},
+		{`<figure><img src="a.png" alt="A" title="T"><figcaption>Cap</figcaption></figure>`, FigureCaptionAlt, "\n\n![A](a.png \"T\")\n\n*Cap*\n"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetFigureStyle(htmlText.style)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestImageSrc(t *testing.T) {
+	htmlTexts := []struct {
+		text     string
+		maxWidth int
+		expect   string
+	}{
+		{`<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" data-src="real.jpg" alt="a">`, 0, "![a](real.jpg)"},
+		{`<img data-original="o.jpg" src="/img/blank.gif">`, 0, "![](o.jpg)"},
+		{`<img src="s.jpg" srcset="s.jpg 300w, m.jpg 600w, l.jpg 1200w">`, 0, "![](l.jpg)"},
+		{`<img src="s.jpg" srcset="s.jpg 300w, m.jpg 600w, l.jpg 1200w">`, 700, "![](m.jpg)"},
+		{`<img src="s.jpg" srcset="m.jpg 600w, l.jpg 1200w">`, 200, "![](m.jpg)"},
+		{`<picture><source srcset="a.webp 1x, a2.webp 2x" type="image/webp"><img src="a.jpg"></picture>`, 0, "![](a2.webp)"},
+		{`<img src="https://example.com/p.gif" width="1" height="1">`, 0, ""},
+		{`<img src="/img/spacer.gif">`, 0, ""},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetImageMaxWidth(htmlText.maxWidth)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestImageAttr(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		style  ImageAttrStyle
+		expect string
+	}{
+		{`<img src="a.png" alt="A" width="300" class="diagram">`, ImageAttrDrop, "![A](a.png)"},
+		{`<img src="a.png" alt="A" width="300" class="diagram">`, ImageAttrHTML, `<img src="a.png" alt="A" width="300" class="diagram">`},
+		{`<img src="a.png" alt="A" class="diagram">`, ImageAttrHTML, "![A](a.png)"},
+		{`<img src="a.png" alt="A &amp; B" title="T" height="20" align="left">`, ImageAttrHTML, `<img src="a.png" alt="A &amp; B" title="T" height="20" align="left">`},
+		{`<img src="a.png" alt="A" width="300" class="diagram wide">`, ImageAttrBraces, "![A](a.png){width=300 .diagram .wide}"},
+		{`<img src="a.png" alt="A">`, ImageAttrBraces, "![A](a.png)"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetImageAttrStyle(htmlText.style)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		raw    bool
+		expect string
+	}{
+		{`<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" title="Song"></iframe>`, false, "[![Song](https://img.youtube.com/vi/dQw4w9WgXcQ/hqdefault.jpg)](https://www.youtube.com/watch?v=dQw4w9WgXcQ)"},
+		{`<iframe src="//player.vimeo.com/video/76979871"></iframe>`, false, "[Vimeo video](https://vimeo.com/76979871)"},
+		{`<iframe src="//player.bilibili.com/player.html?aid=1&bvid=BV1xx411c7mD&page=1"></iframe>`, false, "[Bilibili video](https://www.bilibili.com/video/BV1xx411c7mD)"},
+		{`<iframe src="https://codepen.io/team/codepen/embed/PNaGbb"></iframe>`, false, "[CodePen](https://codepen.io/team/codepen/pen/PNaGbb)"},
+		{`<video poster="p.jpg" controls><source src="v.mp4" type="video/mp4">Not supported</video>`, false, "[![v.mp4](p.jpg)](v.mp4)"},
+		{`<audio src="a.mp3" title="Podcast"></audio>`, false, "[Podcast](a.mp3)"},
+		{`<iframe src="https://example.com/map"></iframe>`, true, "\n\n<iframe src=\"https://example.com/map\"></iframe>\n"},
+		{`<embed src="https://example.com/x.swf">`, false, "[https://example.com/x.swf](https://example.com/x.swf)"},
+		{`<iframe src="https://example.com/tweet/1"></iframe>`, false, "[Tweet 1](https://example.com/tweet/1)"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetEmbedRawHTML(htmlText.raw)
+		h.AddEmbedProvider(func(src *url.URL, n *html.Node) (string, bool) {
+			if !strings.HasPrefix(src.Path, "/tweet/") {
+				return "", false
+			}
+			return "[Tweet " + path.Base(src.Path) + "](" + src.String() + ")", true
+		})
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestGFM(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<p>a</p><p>b</p>`, "a\n\nb"},
+		{`<h2>Title</h2><p>1*2_3 <del>old</del></p>`, "## Title\n\n1\\*2_3 ~~old~~"},
+		{`<ul><li><input type="checkbox" checked> done</li><li><input type="checkbox"> todo</li></ul>`, "- [x] done\n- [ ] todo"},
+		{`<table><tr><th>a</th><th align="right">b</th></tr><tr><td>1</td><td>2|3</td></tr></table>`, "| a | b |\n| --- | ---: |\n| 1 | 2\\|3 |"},
+		{`<pre><code class="language-go">fmt.Println()</code></pre>`, "```go\nfmt.Println()\n```"},
+		{`<p>x<sub>2</sub> <a href="/a b">link</a></p>`, "x<sub>2</sub> [link](/a%20b)"},
+		{`<blockquote><p>x</p><blockquote></blockquote><blockquote><blockquote><p>y<br><br>z</p></blockquote></blockquote></blockquote>`, "> x\n>\n> >\n>\n> > > y\\\n> > > z"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetFlavor(GFM)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestCommonMark(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<p>~1 <del>old</del></p>`, "~1 <del>old</del>"},
+		{`<table><tr><th>a</th></tr><tr><td>1</td></tr></table>`, "<table><tbody><tr><th>a</th></tr><tr><td>1</td></tr></tbody></table>"},
+		{`<ul><li><input type="checkbox" checked> done</li></ul>`, "- <input type=\"checkbox\" checked=\"\"/> done"},
+		{`<p>see https://example.com</p>`, "see https://example.com"},
+		{`<div class="admonition warning"><p class="admonition-title">Careful</p><p>Hot</p></div>`, "> **Careful**\n> Hot"},
+		{`<div class="admonition tip"><p>Salt</p><p>Pepper</p></div>`, "> **Tip**\n> Salt\n>\n> Pepper"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetFlavor(CommonMark)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestPandoc(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<h2 id="intro" class="x">Intro</h2>`, "## Intro {#intro .x}"},
+		{`<p>H<sub>2</sub>O x<sup>a b</sup> 2^3~</p>`, "H~2~O x^a\\ b^ 2\\^3\\~"},
+		{`<div id="side"><p>box</p><p>::: no</p></div>`, "::: {#side}\nbox\n\n\\::: no\n:::"},
+		{`<div class="admonition note"><p class="admonition-title">Heads</p><p>body</p></div>`, "::: note\n::: title\nHeads\n:::\n\nbody\n:::"},
+		{`<dl><dt>Term</dt><dd>Definition</dd></dl>`, "Term\n: Definition"},
+		{`<figure><img src="a.png"><figcaption>Cap</figcaption></figure>`, "![Cap](a.png)"},
+		{`<table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></table>`, "| a | b |\n| --- | --- |\n| 1 | 2 |"},
+		{`<table><tr><th>Name</th><th align="right">Notes</th></tr><tr><td>a</td><td><p>one</p><ul><li>x</li><li>y</li></ul></td></tr></table>`,
+			"+------+-------+\n| Name | Notes |\n+======+======:+\n| a    | one   |\n|      |       |\n|      | - x   |\n|      | - y   |\n+------+-------+"},
+		{`<table><tr><th>名前</th><th>Notes</th></tr><tr><td>日本</td><td><p>x</p><ul><li>表</li></ul></td></tr></table>`,
+			"+------+-------+\n| 名前 | Notes |\n+======+=======+\n| 日本 | x     |\n|      |       |\n|      | - 表  |\n+------+-------+"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetFlavor(Pandoc)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestAsciiDoc(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<h2 id="intro">Intro</h2>`, "[[intro]]\n=== Intro"},
+		{`<p><em>em</em> <strong>bold</strong> <code>x</code> <del>old</del> *star* {attr}</p>`, "_em_ *bold* `+x+` [.line-through]#old# \\*star* \\{attr}"},
+		{`<p><a href="https://a.com">a</a> <a href="/rel">rel</a> <a href="https://b.com">https://b.com</a> <img src="a.png" alt="A"></p>`, "https://a.com[a] link:/rel[rel] https://b.com image:a.png[A]"},
+		{`<ul><li>one</li><li>two<ul><li>nested</li></ul></li><li><p>para</p><p>more</p></li></ul>`, "* one\n* two\n** nested\n* para\n+\nmore"},
+		{`<ol start="3"><li>three</li></ol>`, "[start=3]\n. three"},
+		{`<pre><code class="language-go">fmt.Println()</code></pre>`, "[source,go]\n----\nfmt.Println()\n----"},
+		{`<blockquote><p>quoted</p></blockquote><hr>`, "____\nquoted\n____\n\n'''"},
+		{`<table><tr><th>a</th><th align="right">b</th></tr><tr><td colspan="2">1</td></tr></table>`, "[%header,cols=\"1,>\"]\n|===\n\n|a\n|b\n\n2+|1\n|==="},
+		{`<div class="admonition warning"><p class="admonition-title">Careful</p><p>body</p></div>`, "[WARNING]\n.Careful\n====\nbody\n===="},
+		{`<p>- not a list</p>`, "{empty}- not a list"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetRenderer(AsciiDoc)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestRST(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<h2 id="intro">Intro</h2>`, ".. _intro:\n\nIntro\n-----"},
+		{`<h1>日本語</h1>`, "日本語\n======"},
+		{`<p><em>em</em> <strong>bold</strong> <code>x</code> *star* word_ a.. b</p>`, "*em* **bold** ``x`` \\*star* word\\_ a\\.. b"},
+		{`<p><a href="https://a.com">a</a> <a href="https://b.com">https://b.com</a></p>`, "`a <https://a.com>`__ https://b.com"},
+		{`<p>See <img src="a.png" alt="A"> here</p>`, "See\n\n.. image:: a.png\n   :alt: A\n\nhere"},
+		{`<ul><li>one</li><li>two</li></ul>`, "- one\n- two"},
+		{`<ol start="3"><li>three<ul><li>nested</li></ul></li></ol>`, "3. three\n\n   - nested"},
+		{`<pre><code class="language-go">fmt.Println()</code></pre>`, ".. code-block:: go\n\n   fmt.Println()"},
+		{`<pre>plain</pre><blockquote><p>quoted</p></blockquote>`, "::\n\n   plain\n\n..\n\n   quoted"},
+		{`<table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></table>`, "+-----+-----+\n| a   | b   |\n+=====+=====+\n| 1   | 2   |\n+-----+-----+"},
+		{`<div class="admonition note"><p>plain</p></div>`, ".. note::\n\n   plain"},
+		{`<p>- not a list</p><p>1. not a list</p><p>ends with::</p>`, "\\- not a list\n\n1\\. not a list\n\nends with:\\:"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetRenderer(RST)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestChat(t *testing.T) {
+	htmlTexts := []struct {
+		renderer Renderer
+		text     string
+		expect   string
+	}{
+		{Slack, `<h2>Build passed</h2><p><em>a</em> <strong>b</strong> <del>c</del> <code>d</code> 1 &lt; 2 &amp; <a href="https://a.com/?a=1&b=2">link</a></p>`, "*Build passed*\n\n_a_ *b* ~c~ `d` 1 &lt; 2 &amp; <https://a.com/?a=1&amp;b=2|link>"},
+		{Slack, `<ul><li>one<ul><li>two</li></ul></li></ul><p><img src="a.png" alt="A"></p>`, "• one\n  ◦ two\n\n<a.png|A>"},
+		{TelegramMarkdownV2, `<h2>Build #42 passed!</h2><p>1+1=2. <em>a_b</em> <code>x` + "`" + `y</code> <a href="https://a.com/(x)">a.com</a></p>`, "*Build \\#42 passed\\!*\n\n1\\+1\\=2\\. _a\\_b_ `x\\`y` [a\\.com](https://a.com/(x\\))"},
+		{TelegramMarkdownV2, `<ol start="3"><li>three</li></ol><pre><code class="language-go">a\b</code></pre>`, "3\\. three\n\n```go\na\\\\b\n```"},
+		{TelegramHTML, `<h2>Build</h2><p><em>a</em> <strong>b</strong> <del>c</del> <code>&lt;d&gt;</code> <a href="https://a.com">a</a></p><blockquote><p>q</p></blockquote>`, "<b>Build</b>\n\n<i>a</i> <b>b</b> <s>c</s> <code>&lt;d&gt;</code> <a href=\"https://a.com\">a</a>\n\n<blockquote>q</blockquote>"},
+		{Discord, `<h2>Build</h2><h5>Small</h5><p>a_b *c* <a href="https://a.com">https://a.com</a></p><p>- dash</p>`, "## Build\n\n**Small**\n\na\\_b \\*c\\* https://a.com\n\n\\- dash"},
+		{Discord, `<table><tr><th>Name</th><th>Value</th></tr><tr><td>alpha</td><td>1</td></tr></table>`, "```\nName  | Value\n------+------\nalpha | 1\n```"},
+		{Discord, `<table><tr><th>名前</th><th>Value</th></tr><tr><td>a</td><td>1</td></tr></table>`, "```\n名前 | Value\n-----+------\na    | 1\n```"},
+		{TelegramMarkdownV2, `<div class="admonition warning"><p>body</p></div><hr>`, "> *Warning*\n> body\n\n———"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetRenderer(htmlText.renderer)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+
+	// a callout without kind nor title is quoted as it is
+	if text := Slack.Admonition("", "", "body"); text != "> body" {
+		t.Errorf("Expect \"%s\" but got \"%s\"", "> body", text)
+	}
+}
+
+func TestConfluence(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<p>See <ac:link><ri:page ri:content-title="Release Notes" /><ac:plain-text-link-body><![CDATA[the notes]]></ac:plain-text-link-body></ac:link> <ac:emoticon ac:name="tick" /> <ac:structured-macro ac:name="status"><ac:parameter ac:name="title">Done</ac:parameter></ac:structured-macro></p><ac:structured-macro ac:name="toc" />`, "See [the notes](Release%20Notes) ✅ **DONE**"},
+		{`<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">go</ac:parameter><ac:plain-text-body><![CDATA[if a < b {
+}]]></ac:plain-text-body></ac:structured-macro>`, "```go\nif a < b {\n}\n```"},
+		{`<ac:structured-macro ac:name="info"><ac:parameter ac:name="title">Heads up</ac:parameter><ac:rich-text-body><p>Read <strong>this</strong></p></ac:rich-text-body></ac:structured-macro>`, "> [!NOTE]\n> **Heads up**\n> Read **this**"},
+		{`<ac:structured-macro ac:name="expand"><ac:parameter ac:name="title">More</ac:parameter><ac:rich-text-body><p>Hidden</p></ac:rich-text-body></ac:structured-macro>`, "<details>\n<summary>More</summary>\n\nHidden\n\n</details>"},
+		{`<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>Ship</ac:task-body></ac:task><ac:task><ac:task-id>2</ac:task-id><ac:task-status>incomplete</ac:task-status><ac:task-body>Tell</ac:task-body></ac:task></ac:task-list>`, "- [x] Ship\n- [ ] Tell"},
+		{`<p><ac:image ac:alt="chart"><ri:attachment ri:filename="chart.png" /></ac:image> <ac:structured-macro ac:name="jira"><ac:parameter ac:name="server">https://jira.example.com</ac:parameter><ac:parameter ac:name="key">APP-7</ac:parameter></ac:structured-macro> <ac:structured-macro ac:name="jira"><ac:parameter ac:name="server">System JIRA</ac:parameter><ac:parameter ac:name="key">APP-8</ac:parameter></ac:structured-macro></p>`, "![chart](chart.png) [APP-7](https://jira.example.com/browse/APP-7) APP-8"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MDFromConfluence(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetFlavor(GFM)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+
+	// the storage has no source once rewritten, its size is still limited
+	h, err := NewH2MDFromConfluence(strings.Repeat("<p>a</p>", 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetLimits(Limits{MaxInputBytes: 1000})
+	if _, _, err := h.Convert(); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expect \"%v\" but got \"%v\"", ErrInputTooLarge, err)
+	}
+}
+
+func TestJira(t *testing.T) {
+	htmlTexts := []struct {
+		text   string
+		expect string
+	}{
+		{`<h2 id="intro">Intro</h2><p><em>a</em> <strong>b</strong> <del>c</del> <code>d{e}</code> [x] <a href="https://a.com">link</a> <a href="https://a.com">https://a.com</a> <img src="a.png" alt="A"></p>`, "h2. {anchor:intro}Intro\n\n_a_ *b* -c- {{d\\{e\\}}} \\[x\\] [link|https://a.com] [https://a.com] !a.png|alt=A!"},
+		{`<ol><li>one<ul><li>a<ol><li>deep</li></ol></li></ul></li><li>two</li></ol>`, "# one\n#* a\n#*# deep\n# two"},
+		{`<pre><code class="language-go">x := 1</code></pre><pre>plain</pre><blockquote><p>q</p></blockquote><hr>`, "{code:go}\nx := 1\n{code}\n\n{noformat}\nplain\n{noformat}\n\n{quote}\nq\n{quote}\n\n----"},
+		{`<table><tr><th>A</th><th>B</th></tr><tr><td colspan="2">x|y</td></tr></table>`, "||A||B||\n|x\\|y| |"},
+		{`<div class="admonition note"><p class="admonition-title">Heads up</p><p>body</p></div><p>* star<br>h1. title</p>`, "{info:title=Heads up}\nbody\n{info}\n\n\\* star\nh1\\. title"},
+	}
+
+	for _, htmlText := range htmlTexts {
+		h, err := NewH2MD(htmlText.text)
+		if err != nil {
+			t.Error(err)
+		}
+		h.SetRenderer(Jira)
+		text := h.Text()
+		if text != htmlText.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", htmlText.expect, text)
+		}
+	}
+}
+
+func TestConvertDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "site")
+	files := map[string]string{
+		"index.html":    "<h1>Index</h1>",
+		"a/page.htm":    "<p><b>page</b></p>",
+		"a/b/deep.html": "<ul><li>deep</li></ul>",
+		"a/style.css":   "p {}",
+	}
+	for name, text := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gfm := func(src []byte) (*H2MD, error) {
+		h, err := NewH2MD(string(src))
+		if err == nil {
+			h.SetFlavor(GFM)
+		}
+		return h, err
+	}
+
+	// the outputs and summaries do not depend on the number of workers
+	var outputs []string
+	for _, workers := range []int{1, 8} {
+		dst := filepath.Join(dir, fmt.Sprint("out", workers))
+		summary, err := ConvertDir(context.Background(), src, dst, Batch{Workers: workers, New: gfm})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Total != 3 || summary.Converted != 3 {
+			t.Errorf("Expect 3 converted files but got %+v", summary)
+		}
+		var text string
+		for _, r := range summary.Files {
+			data, err := os.ReadFile(filepath.Join(dst, r.Output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			text += r.Source + ":" + string(data)
+		}
+		outputs = append(outputs, text)
+	}
+	expect := filepath.Join("a", "b", "deep.html") + ":- deep\n" + filepath.Join("a", "page.htm") + ":**page**\nindex.html:# Index\n"
+	for _, text := range outputs {
+		if text != expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", expect, text)
+		}
+	}
+
+	// unchanged files are skipped, changed ones converted again
+	dst := filepath.Join(dir, "out1")
+	if err := os.WriteFile(filepath.Join(src, "index.html"), []byte("<h1>New</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var done int
+	summary, err := ConvertDir(context.Background(), src, dst, Batch{New: gfm, Progress: func(n, total int, r BatchResult) { done = n }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Converted != 1 || summary.Skipped != 2 || done != 3 {
+		t.Errorf("Expect 1 converted and 2 skipped files but got %+v", summary)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "index.md")); string(data) != "# New\n" {
+		t.Errorf("Expect \"# New\\n\" but got \"%s\"", data)
+	}
+	summary, err = ConvertDir(context.Background(), src, dst, Batch{New: gfm, Key: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Converted != 3 {
+		t.Errorf("Expect a new key to convert every file but got %+v", summary)
+	}
+}
+
+// pageFetcher fetcher of a fixed page
+type pageFetcher struct {
+	page *Page
+}
+
+func (f pageFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	return f.page, nil
+}
+
+func TestNewH2MDFromURL(t *testing.T) {
+	var userAgent string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/page", http.StatusFound)
+	})
+	mux.HandleFunc("/docs/page", func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "text/html; charset=gbk")
+		// <p>中文 <a href="next">next</a> <img src="/a.png"></p> in GBK
+		w.Write([]byte("<p>\xd6\xd0\xce\xc4 <a href=\"next\">next</a> <img src=\"/a.png\"></p>"))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("<p>x</p>"), 100))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	h, err := NewH2MDFromURL(context.Background(), ts.URL+"/old", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetFlavor(GFM)
+	expect := "中文 [next](" + ts.URL + "/docs/next) ![](" + ts.URL + "/a.png)"
+	if text := h.Text(); text != expect {
+		t.Errorf("Expect \"%s\" but got \"%s\"", expect, text)
+	}
+	if userAgent != DefaultUserAgent {
+		t.Errorf("Expect \"%s\" but got \"%s\"", DefaultUserAgent, userAgent)
+	}
+
+	fetcher := &HTTPFetcher{MaxBytes: 100, MaxRedirects: 3}
+	for path, expect := range map[string]error{"/loop": ErrTooManyRedirects, "/large": ErrBodyTooLarge} {
+		if _, err := NewH2MDFromURL(context.Background(), ts.URL+path, &URLOptions{Fetcher: fetcher}); !errors.Is(err, expect) {
+			t.Errorf("Expect \"%v\" but got \"%v\"", expect, err)
+		}
+	}
+	if _, err := NewH2MDFromURL(context.Background(), ts.URL+"/missing", nil); err == nil {
+		t.Error("Expect an error for a missing page")
+	}
+
+	base, _ := url.Parse("https://example.com/blog/post")
+	h, err = NewH2MDFromURL(context.Background(), "ignored", &URLOptions{Fetcher: pageFetcher{&Page{URL: base, Body: []byte(`<a href="../about">about</a>`)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := h.Text(); text != "[about](https://example.com/about)" {
+		t.Errorf("Expect \"[about](https://example.com/about)\" but got \"%s\"", text)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	zh := "<p>我们的网站提供中文内容，欢迎访问。</p>"
+	tw := "<p>我們的網站提供中文內容，歡迎訪問。</p>"
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(zh)
+	big5, _ := traditionalchinese.Big5.NewEncoder().String(tw)
+	sjis, _ := japanese.ShiftJIS.NewEncoder().String("<p>日本語のページです。</p>")
+	latin1, _ := charmap.Windows1252.NewEncoder().String("<p>Café crème</p>")
+	htmlTexts := []struct {
+		text        string
+		charset     string
+		contentType string
+		expect      string
+	}{
+		{gbk, "", "", "我们的网站提供中文内容，欢迎访问。"},
+		{big5, "", "", "我們的網站提供中文內容，歡迎訪問。"},
+		{`<meta charset="shift_jis">` + sjis, "", "", "日本語のページです。"},
+		{`<meta http-equiv="Content-Type" content="text/html; charset=big5">` + big5, "", "", "我們的網站提供中文內容，歡迎訪問。"},
+		{big5, "", "text/html; charset=big5", "我們的網站提供中文內容，歡迎訪問。"},
+		{latin1, "", "", "Café crème"},
+		{latin1, "iso-8859-1", "", "Café crème"},
+		{"\xef\xbb\xbf<p>中文</p>", "", "", "中文"},
+		{`<meta charset="gbk"><p>中文</p>`, "", "", "中文"},
+		{gbk, "gbk", "text/html; charset=big5", "我们的网站提供中文内容，欢迎访问。"},
+		{"<p>café naïve … 中文\xff</p>", "", "", "café naïve … 中文\ufffd"},
+		{"<meta charset=\"utf-8\"><p>中文\x80</p>", "", "", "中文\ufffd"},
+	}
+	for _, v := range htmlTexts {
+		h, err := NewH2MDFromReader(strings.NewReader(v.text), &ReaderOptions{Charset: v.charset, ContentType: v.contentType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.SetFlavor(GFM)
+		if text := h.Text(); text != v.expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", v.expect, text)
+		}
+	}
+
+	h, err := NewH2MD(gbk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := h.Text(); text != "我们的网站提供中文内容，欢迎访问。" {
+		t.Errorf("Expect \"我们的网站提供中文内容，欢迎访问。\" but got \"%s\"", text)
+	}
+	// UTF-8 with a stray byte is not decoded by a legacy charset
+	for _, text := range []string{"<p>café naïve … 中文\xff</p>", "<meta charset=\"utf-8\"><p>café\x80</p>"} {
+		h, err := NewH2MD(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text := h.Text(); !strings.HasPrefix(text, "café") || strings.Contains(text, "Ã") {
+			t.Errorf("Expect the UTF-8 text but got \"%s\"", text)
+		}
+	}
+	if _, err := NewH2MDFromReader(strings.NewReader(gbk), &ReaderOptions{Charset: "nope"}); err == nil {
+		t.Error("Expect an error for an unknown charset")
+	}
+}
+
+func TestConvertWarnings(t *testing.T) {
+	h, err := NewH2MD(`<p>text</p>
+<marquee>news</marquee>
+<table><tr><td><ul><li>a</li></ul></td></tr></table>
+<p><img src="a.png"> <img alt="none"> <img src="https://a.com/b.png"></p>
+<svg><circle r="1"></circle></svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, warnings, err := h.Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "news") {
+		t.Errorf("Expect the content of unknown elements but got \"%s\"", text)
+	}
+	expect := []string{
+		"2:1 /html/body/marquee: unknown element <marquee>, its content is kept",
+		"3:1 /html/body/table: table cells hold blocks or span rows, the pipe table flattens them",
+		"4:4 /html/body/p[2]/img[1]: relative image src a.png without base url",
+		"4:22 /html/body/p[2]/img[2]: image without src dropped",
+		"5:1 /html/body/svg: svg drawing dropped",
+	}
+	if len(warnings) != len(expect) {
+		t.Fatalf("Expect %d warnings but got %v", len(expect), warnings)
+	}
+	kinds := []WarningKind{WarnUnknownTag, WarnTable, WarnImage, WarnImage, WarnDropped}
+	for i, w := range warnings {
+		if w.String() != expect[i] {
+			t.Errorf("Expect \"%s\" but got \"%s\"", expect[i], w.String())
+		}
+		if w.Kind != kinds[i] {
+			t.Errorf("Expect \"%s\" but got \"%s\"", kinds[i], w.Kind)
+		}
+	}
+
+	h, _ = NewH2MD(`<table><tr><td><ul><li>a</li></ul></td></tr></table><img src="a.png">`)
+	h.SetFlavor(GFM)
+	h.SetBaseURL("https://a.com/")
+	if _, warnings, _ := h.Convert(); len(warnings) != 0 {
+		t.Errorf("Expect no warnings but got %v", warnings)
+	}
+
+	// the warnings come from the walk writing the output
+	h, _ = NewH2MD(`<p>Some <em>em</em> and <q>q</q><textarea>x</textarea></p>`)
+	h.SetFlavor(GFM)
+	text, warnings, _ = h.Convert()
+	if expect := "Some *em* and \"q\""; text != expect {
+		t.Errorf("Expect \"%s\" but got \"%s\"", expect, text)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != WarnDropped {
+		t.Errorf("Expect the dropped textarea but got %v", warnings)
+	}
+
+	if _, err := NewH2MDFromNode(nil); !errors.Is(err, ErrNilNode) {
+		t.Errorf("Expect \"%v\" but got \"%v\"", ErrNilNode, err)
+	}
+	if _, _, err := (&H2MD{}).Convert(); !errors.Is(err, ErrNilNode) {
+		t.Errorf("Expect \"%v\" but got \"%v\"", ErrNilNode, err)
+	}
+}
+
+func TestConvertWarningsScale(t *testing.T) {
+	text := "<div>" + strings.Repeat("<blink>x</blink>", 20000) + "</div>"
+	h, err := NewH2MD(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	h.Text()
+	walk := time.Since(start)
+	h, err = NewH2MD(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	_, warnings, err := h.Convert()
+	convert := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 20000 || warnings[19999].Path != "/html/body/div/blink[20000]" {
+		t.Fatalf("Expect 20000 warnings up to /html/body/div/blink[20000] but got %d", len(warnings))
+	}
+	// counting the siblings again for each warning takes hundreds of times the walk
+	if convert > 50*walk+time.Second {
+		t.Errorf("Expect the warnings in about the time of the walk %v but got %v", walk, convert)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	deep := strings.Repeat("<div>", 1000) + "text"
+	wide := strings.Repeat("<p>a</p>", 1000)
+	tests := []struct {
+		text   string
+		limits Limits
+		expect error
+	}{
+		{deep, Limits{MaxDepth: 100}, ErrTooDeep},
+		{deep, Limits{MaxDepth: 2000}, nil},
+		{wide, Limits{MaxNodes: 1000}, ErrTooManyNodes},
+		{wide, Limits{MaxInputBytes: 1000}, ErrInputTooLarge},
+		{wide, Limits{MaxOutputBytes: 500}, ErrOutputTooLarge},
+		{wide, DefaultLimits, nil},
+	}
+	for _, test := range tests {
+		h, err := NewH2MD(test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.SetLimits(test.limits)
+		if _, _, err := h.Convert(); !errors.Is(err, test.expect) {
+			t.Errorf("Expect \"%v\" but got \"%v\"", test.expect, err)
+		}
+	}
+
+	h, _ := NewH2MD(wide)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := h.ConvertContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expect \"%v\" but got \"%v\"", context.Canceled, err)
+	}
+	if _, err := NewH2MDFromReader(strings.NewReader(wide), &ReaderOptions{MaxBytes: 100}); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expect \"%v\" but got \"%v\"", ErrInputTooLarge, err)
+	}
+
+	// the input limit is checked before the html is decoded and parsed
+	c := NewConverter()
+	c.SetLimits(Limits{MaxInputBytes: 1000})
+	large := strings.Repeat("<p>\xe9</p>", 100000)
+	for _, convert := range []func(context.Context, string) (string, []Warning, error){c.ConvertString, c.ConvertFragment} {
+		var err error
+		if n := allocated(func() { _, _, err = convert(context.Background(), large) }); n > uint64(len(large)) {
+			t.Errorf("Expect the input refused before parsing but %d bytes were allocated", n)
+		}
+		if !errors.Is(err, ErrInputTooLarge) {
+			t.Errorf("Expect \"%v\" but got \"%v\"", ErrInputTooLarge, err)
+		}
+	}
+}
+
+// expiringContext context whose deadline is exceeded once its error was checked after times
+type expiringContext struct {
+	context.Context
+	after  int
+	checks int
+}
+
+func (c *expiringContext) Err() error {
+	if c.checks++; c.after >= 0 && c.checks > c.after {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func TestLimitsNestedQuotes(t *testing.T) {
+	quotes := strings.Repeat("<blockquote>", 500) + strings.Repeat("a<br>", 200000)
+	short := strings.Repeat("<blockquote>", 3) + strings.Repeat("a<br>", 50000)
+	tests := []struct {
+		text   string
+		flavor *Flavor
+		limits Limits
+		expect error
+	}{
+		{quotes, nil, DefaultLimits, ErrOutputTooLarge},
+		{quotes, &GFM, DefaultLimits, ErrOutputTooLarge},
+		{quotes, nil, Limits{MaxOutputBytes: 1 << 16}, ErrOutputTooLarge},
+		{quotes, &GFM, Limits{MaxOutputBytes: 1 << 16}, ErrOutputTooLarge},
+		{short, nil, DefaultLimits, nil},
+		{short, &GFM, DefaultLimits, nil},
+	}
+	for _, test := range tests {
+		h, err := NewH2MD(test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.flavor != nil {
+			h.SetFlavor(*test.flavor)
+		}
+		h.SetLimits(test.limits)
+		out, _, err := h.ConvertContext(context.Background())
+		if !errors.Is(err, test.expect) {
+			t.Errorf("Expect \"%v\" but got \"%v\"", test.expect, err)
+		}
+		if max := test.limits.MaxOutputBytes; errors.Is(err, ErrOutputTooLarge) && err.Error() != fmt.Sprintf("h2md: output too large: more than %d bytes", max) {
+			t.Errorf("Expect the conversion to abort at %d bytes but got \"%v\"", max, err)
+		}
+		if max := test.limits.MaxOutputBytes; len(out) > max {
+			t.Errorf("Expect at most %d bytes but got %d", max, len(out))
+		}
+		if test.expect == nil && !strings.Contains(out, "> > > a") {
+			t.Errorf("Expect \"> > > a\" but got \"%.20s\"", out)
+		}
+	}
+
+	// the checks of the walk are counted on the lines without quotes, the context then
+	// expires while the quoted lines are written and the conversion stops at the next check
+	lines := strings.Repeat("a<br>", 50000)
+	for _, flavor := range []*Flavor{nil, &GFM} {
+		h, _ := NewH2MD(lines)
+		quoted, _ := NewH2MD(strings.Repeat("<blockquote>", 500) + lines)
+		if flavor != nil {
+			h.SetFlavor(*flavor)
+			quoted.SetFlavor(*flavor)
+		}
+		ctx := &expiringContext{Context: context.Background(), after: -1}
+		if _, _, err := h.ConvertContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+		ctx = &expiringContext{Context: context.Background(), after: ctx.checks + 20}
+		if _, _, err := quoted.ConvertContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expect \"%v\" but got \"%v\"", context.DeadlineExceeded, err)
+		}
+		if ctx.checks > ctx.after+2 {
+			t.Errorf("Expect the conversion to stop after %d checks but it made %d", ctx.after+1, ctx.checks)
+		}
+	}
+}
+
+// allocated return the bytes allocated while running f
+func allocated(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestDefaultOutputScales(t *testing.T) {
+	for _, c := range []struct {
+		wrap, element string
+	}{
+		{"%s", "<div>x</div>"},
+		{"<ul>%s</ul>", "<li>x</li>"},
+		{"<table>%s</table>", "<tr><td>x</td></tr>"},
+	} {
+		var sizes []uint64
+		for _, n := range []int{5000, 20000} {
+			text := fmt.Sprintf(c.wrap, strings.Repeat(c.element, n))
+			sizes = append(sizes, allocated(func() {
+				h, err := NewH2MD(text)
+				if err != nil {
+					t.Fatal(err)
+				}
+				h.Text()
+			}))
+		}
+		// four times the input allocates about four times the bytes, sixteen when quadratic
+		if ratio := float64(sizes[1]) / float64(sizes[0]); ratio > 8 {
+			t.Errorf("Expect %s allocations to grow with the input but they grew %.1f times for 4 times the input", c.element, ratio)
+		}
+	}
+}
+
+func FuzzConvert(f *testing.F) {
+	for _, seed := range []string{
+		"<h1>Title</h1><p>text <b>bold</b> <a href=\"/a\">link</a></p>",
+		"<blockquote><blockquote><p>quote</p></blockquote></blockquote>",
+		"<table><tr><td rowspan=2><ul><li>a</li></ul></td></tr></table>",
+		"<ul><li>a<ol><li>b</li></ol></li></ul><pre><code class=\"language-go\">x</code></pre>",
+		strings.Repeat("<div><blockquote>", 50),
+	} {
+		f.Add(seed)
+	}
+	limits := Limits{MaxInputBytes: 1 << 16, MaxDepth: 64, MaxNodes: 4096, MaxOutputBytes: 1 << 18}
+	f.Fuzz(func(t *testing.T, text string) {
+		for _, gfm := range []bool{false, true} {
+			h, err := NewH2MD(text)
+			if err != nil {
+				return
+			}
+			if gfm {
+				h.SetFlavor(GFM)
+			}
+			h.SetLimits(limits)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			out, _, err := h.ConvertContext(ctx)
+			cancel()
+			switch {
+			case err == nil:
+				if len(out) > limits.MaxOutputBytes {
+					t.Errorf("Expect at most %d bytes but got %d", limits.MaxOutputBytes, len(out))
+				}
+			case errors.Is(err, ErrInputTooLarge), errors.Is(err, ErrTooDeep), errors.Is(err, ErrTooManyNodes),
+				errors.Is(err, ErrOutputTooLarge), errors.Is(err, context.DeadlineExceeded):
+			default:
+				t.Errorf("Expect a limit error but got \"%v\"", err)
+			}
+		}
+	})
+}
+
+func TestNonDestructive(t *testing.T) {
+	node, err := html.Parse(strings.NewReader(`<h1> Title </h1><ul><li> a <ul><li>b</li></ul></li></ul>
+<table><tr><th> h </th></tr><tr><td> c </td></tr></table><blockquote><p> quote </p></blockquote><pre><code> x
+  y </code></pre>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before bytes.Buffer
+	html.Render(&before, node)
+	for _, flavor := range []*Flavor{nil, &GFM} {
+		h, _ := NewH2MDFromNode(node)
+		if flavor != nil {
+			h.SetFlavor(*flavor)
+		}
+		expect := h.Text()
+		if text := h.Text(); text != expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", expect, text)
+		}
+		texts := make(chan string, 8)
+		for i := 0; i < cap(texts); i++ {
+			go func() {
+				text, _, _ := h.Convert()
+				texts <- text
+			}()
+		}
+		for i := 0; i < cap(texts); i++ {
+			if text := <-texts; text != expect {
+				t.Errorf("Expect \"%s\" but got \"%s\"", expect, text)
+			}
+		}
+	}
+	var after bytes.Buffer
+	html.Render(&after, node)
+	if after.String() != before.String() {
+		t.Errorf("Expect \"%s\" but got \"%s\"", before.String(), after.String())
+	}
+}
+
+func TestConverter(t *testing.T) {
+	c := NewConverter()
+	c.SetFlavor(GFM)
+	c.SetBaseURL("https://a.com/")
+	c.Replace("href", func(val string, n *html.Node) string {
+		return val + "?ref=h2md"
+	})
+	ctx := context.Background()
+	htmlText := `<h1>Title</h1><p><a href="page">link</a> <marquee>x</marquee></p>`
+	expect := "# Title\n\n[link](https://a.com/page?ref=h2md) x"
+
+	node, _ := html.Parse(strings.NewReader(htmlText))
+	converts := map[string]func() (string, []Warning, error){
+		"string":   func() (string, []Warning, error) { return c.ConvertString(ctx, htmlText) },
+		"reader":   func() (string, []Warning, error) { return c.ConvertReader(ctx, strings.NewReader(htmlText), nil) },
+		"node":     func() (string, []Warning, error) { return c.ConvertNode(ctx, node) },
+		"fragment": func() (string, []Warning, error) { return c.ConvertFragment(ctx, htmlText) },
+	}
+	paths := map[string]string{
+		"string":   "1:42 /html/body/p/marquee",
+		"reader":   "1:42 /html/body/p/marquee",
+		"node":     "/html/body/p/marquee",
+		"fragment": "1:42 /p/marquee",
+	}
+	for name, convert := range converts {
+		text, warnings, err := convert()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != expect {
+			t.Errorf("Expect \"%s\" but got \"%s\"", expect, text)
+		}
+		if len(warnings) != 1 || !strings.HasPrefix(warnings[0].String(), paths[name]) {
+			t.Errorf("Expect \"%s\" but got %v", paths[name], warnings)
+		}
+	}
+
+	// one converter for many documents at once
+	texts := make(chan string, 8)
+	for i := 0; i < cap(texts); i++ {
+		go func(i int) {
+			text, _, _ := c.ConvertString(ctx, fmt.Sprintf("<p><b>%d</b></p>", i))
+			texts <- text
+		}(i)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < cap(texts); i++ {
+		seen[<-texts] = true
+	}
+	for i := 0; i < cap(texts); i++ {
+		if expect := fmt.Sprintf("**%d**", i); !seen[expect] {
+			t.Errorf("Expect \"%s\" in %v", expect, seen)
+		}
+	}
+
+	if _, _, err := c.ConvertNode(ctx, nil); !errors.Is(err, ErrNilNode) {
+		t.Errorf("Expect \"%v\" but got \"%v\"", ErrNilNode, err)
+	}
+	var zero Converter
+	if text, _, _ := zero.ConvertString(ctx, "<p><b>zero</b></p>"); text != "**zero**" {
+		t.Errorf("Expect \"**zero**\" but got \"%s\"", text)
+	}
+
+	// a document without converter converts with the default settings
+	h := &H2MD{Node: node}
+	if text := h.Text(); !strings.HasPrefix(text, "\n# Title") {
+		t.Errorf("Expect \"\n# Title\" but got \"%s\"", text)
+	}
+	if _, _, err := h.Convert(); err != nil {
+		t.Error(err)
+	}
+	if href := h.Attr("href", node.LastChild.LastChild.LastChild.FirstChild); href != "page" {
+		t.Errorf("Expect \"page\" but got \"%s\"", href)
+	}
+
+	// the reader stops at the input limit instead of reading the whole stream
+	c.SetLimits(Limits{MaxInputBytes: 64})
+	r := &countingReader{r: strings.NewReader(strings.Repeat("<p>x</p>", 1<<16))}
+	if _, _, err := c.ConvertReader(ctx, r, nil); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expect \"%v\" but got \"%v\"", ErrInputTooLarge, err)
+	}
+	if r.n > 4096 {
+		t.Errorf("Expect at most 4096 bytes read but got %d", r.n)
+	}
+}
+
+// countingReader reader counting the bytes read
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
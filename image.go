@@ -0,0 +1,237 @@
+package h2md
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// FigureStyle how figure captions are written
+type FigureStyle int
+
+const (
+	// FigureCaption image followed by the caption as an italic paragraph
+	FigureCaption FigureStyle = iota
+	// FigureCaptionAlt caption used as the image alt, or title when the alt is set
+	FigureCaptionAlt
+)
+
+// ImageAttrStyle how image width, height, align and class are written
+type ImageAttrStyle int
+
+const (
+	// ImageAttrDrop drop the attributes
+	ImageAttrDrop ImageAttrStyle = iota
+	// ImageAttrHTML raw img tag when the image is sized
+	ImageAttrHTML
+	// ImageAttrBraces Pandoc and kramdown attributes: ![alt](src){width=300}
+	ImageAttrBraces
+)
+
+// imageAttrs attributes kept by the image attribute styles
+var imageAttrs = []string{"width", "height", "align", "class"}
+
+// SetFigureStyle Set how figure captions are written
+func (h *Converter) SetFigureStyle(style FigureStyle) {
+	h.figureStyle = style
+}
+
+// SetImageAttrStyle Set how image width, height, align and class are written
+func (h *Converter) SetImageAttrStyle(style ImageAttrStyle) {
+	h.imageAttrStyle = style
+}
+
+// image return the image, keeping its attributes in the chosen style
+func (h *Converter) image(n *html.Node, alt, src, title string) string {
+	switch h.imageAttrStyle {
+	case ImageAttrHTML:
+		_, width := attrOk(n, "width")
+		_, height := attrOk(n, "height")
+		_, align := attrOk(n, "align")
+		if !width && !height && !align {
+			break
+		}
+		s := `<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(alt) + `"`
+		if title != "" {
+			s += ` title="` + html.EscapeString(title) + `"`
+		}
+		for _, name := range imageAttrs {
+			if v, ok := attrOk(n, name); ok {
+				s += " " + name + `="` + html.EscapeString(v) + `"`
+			}
+		}
+		return s + ">"
+	case ImageAttrBraces:
+		var attrs []string
+		for _, name := range imageAttrs {
+			v := strings.TrimSpace(attr(n, name))
+			if v == "" {
+				continue
+			}
+			if name != "class" {
+				attrs = append(attrs, name+"="+v)
+				continue
+			}
+			for _, class := range strings.Fields(v) {
+				attrs = append(attrs, "."+class)
+			}
+		}
+		if len(attrs) > 0 {
+			return image(alt, src, title) + "{" + strings.Join(attrs, " ") + "}"
+		}
+	}
+	return image(alt, src, title)
+}
+
+// image return the markdown image
+func image(alt, src, title string) string {
+	if title != "" {
+		return "![" + alt + "](" + src + " \"" + strings.ReplaceAll(title, "\"", "\\\"") + "\")"
+	}
+	return "![" + alt + "](" + src + ")"
+}
+
+// figure return the image and caption elements of the figure
+func figure(n *html.Node) (img, caption *html.Node) {
+	img = findElement(n, func(e *html.Node) bool { return e.Data == "img" })
+	caption = findElement(n, func(e *html.Node) bool { return e.Data == "figcaption" })
+	return
+}
+
+// lazySrcAttrs attributes used by lazy loading scripts for the real image
+var lazySrcAttrs = []string{"data-src", "data-original", "data-lazy-src", "data-actualsrc", "data-original-src", "data-url"}
+
+// lazySrcsetAttrs attributes used for the responsive candidates, in order of preference
+var lazySrcsetAttrs = []string{"data-srcset", "data-lazy-srcset", "srcset"}
+
+// placeholders spacer and tracking pixel urls
+var placeholders = []string{
+	"spacer.gif", "blank.gif", "pixel.gif", "transparent.gif", "clear.gif", "1x1.",
+	"facebook.com/tr", "google-analytics.com", "doubleclick.net", "/pixel?", "/track?",
+}
+
+// SetImageMaxWidth Prefer the largest srcset candidate not wider than width, 0 picks the largest
+func (h *Converter) SetImageMaxWidth(width int) {
+	h.imageMaxWidth = width
+}
+
+// srcsetCandidate an image candidate from a srcset attribute
+type srcsetCandidate struct {
+	url   string
+	width float64
+}
+
+// parseSrcset parse "a.png 300w, b.png 2x" candidates,
+// densities are weighted so 2x ranks as a 2000w image
+func parseSrcset(srcset string) []srcsetCandidate {
+	var candidates []srcsetCandidate
+	for _, part := range strings.Split(srcset, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		c := srcsetCandidate{url: fields[0], width: 1000}
+		if len(fields) > 1 {
+			d := fields[1]
+			v, err := strconv.ParseFloat(d[:len(d)-1], 64)
+			switch {
+			case err != nil:
+			case strings.HasSuffix(d, "w"):
+				c.width = v
+			case strings.HasSuffix(d, "x"):
+				c.width = v * 1000
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// isPlaceholder report whether the url is an empty, inline or spacer image
+func isPlaceholder(src string) bool {
+	if src == "" || strings.HasPrefix(src, "data:") || strings.HasPrefix(src, "about:") {
+		return true
+	}
+	lower := strings.ToLower(src)
+	for _, p := range placeholders {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrackingPixel report whether the image is sized as a tracking pixel
+func isTrackingPixel(n *html.Node) bool {
+	size := func(name string) bool {
+		v, ok := attrOk(n, name)
+		if !ok {
+			return false
+		}
+		i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(v), "px"))
+		return err == nil && i <= 1
+	}
+	return size("width") && size("height")
+}
+
+// imageSrc return the best image url of the img element, or "" for placeholders
+func (h *Converter) imageSrc(n *html.Node) string {
+	if isTrackingPixel(n) {
+		return ""
+	}
+	var candidates []srcsetCandidate
+	var srcsets = func(e *html.Node) {
+		for _, name := range lazySrcsetAttrs {
+			if v := attr(e, name); v != "" {
+				candidates = append(candidates, parseSrcset(v)...)
+				return
+			}
+		}
+	}
+	if n.Parent != nil && n.Parent.Data == "picture" {
+		for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "source" {
+				srcsets(c)
+			}
+		}
+	}
+	srcsets(n)
+	var src string
+	var best *srcsetCandidate
+	for i := range candidates {
+		c := &candidates[i]
+		if isPlaceholder(c.url) {
+			continue
+		}
+		switch {
+		case best == nil:
+			best = c
+		case h.imageMaxWidth > 0 && best.width > float64(h.imageMaxWidth):
+			if c.width < best.width {
+				best = c
+			}
+		case h.imageMaxWidth > 0 && c.width > float64(h.imageMaxWidth):
+		case c.width > best.width:
+			best = c
+		}
+	}
+	if best != nil {
+		src = best.url
+	} else {
+		for _, name := range append(lazySrcAttrs, "src") {
+			if v := strings.TrimSpace(attr(n, name)); !isPlaceholder(v) {
+				src = v
+				break
+			}
+		}
+	}
+	if src == "" {
+		return ""
+	}
+	src = h.resolve(src)
+	if r, ok := h.replacers["src"]; ok {
+		return r(src, n)
+	}
+	return src
+}
@@ -0,0 +1,238 @@
+package h2md
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Jira renderer writing the wiki markup of Jira and Confluence
+var Jira Renderer = jira{}
+
+// jiraBlockStart lines that would start a heading, a list, a quote or a rule
+var jiraBlockStart = regexp.MustCompile(`^(h[1-6]\. |bq\. |[*#-]+ |----)`)
+
+// jira renderer of Jira wiki markup
+type jira struct{}
+
+// Escape escape the formatting marks that could open a span, macros, links and images
+func (jira) Escape(text string) string {
+	var b strings.Builder
+	rs := []rune(text)
+	at := func(i int) rune {
+		if i >= 0 && i < len(rs) {
+			return rs[i]
+		}
+		return 0
+	}
+	for i, r := range rs {
+		switch r {
+		case '*', '_', '-', '+', '^', '~', '?':
+			if !isAlnum(at(i-1)) && at(i+1) != ' ' && at(i+1) != 0 {
+				b.WriteByte('\\')
+			}
+		case '{', '}', '[', ']', '|', '!', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Paragraph return the inline content as a paragraph, guarding the lines that would start a block
+func (jira) Paragraph(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Trim(line, " "); line == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "h") || strings.HasPrefix(line, "bq"):
+			if jiraBlockStart.MatchString(line) {
+				dot := strings.Index(line, ".")
+				line = line[:dot] + "\\" + line[dot:]
+			}
+		case jiraBlockStart.MatchString(line):
+			line = "\\" + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Heading return hN. text, the id becomes an anchor macro
+func (jira) Heading(level int, text, id string, classes []string) string {
+	if text == "" {
+		return ""
+	}
+	s := "h" + strconv.Itoa(level) + ". "
+	if id != "" {
+		s += "{anchor:" + id + "}"
+	}
+	return s + strings.ReplaceAll(text, "\n", " ")
+}
+
+// ThematicBreak return the horizontal rule
+func (jira) ThematicBreak() string {
+	return "----"
+}
+
+// LineBreak return a line break, new lines break the lines of a paragraph
+func (jira) LineBreak() string {
+	return "\n"
+}
+
+// Emphasis return _text_
+func (jira) Emphasis(text string) string {
+	return wrap([2]string{"_", "_"}, text)
+}
+
+// Strong return *text*
+func (jira) Strong(text string) string {
+	return wrap([2]string{"*", "*"}, text)
+}
+
+// Strikethrough return -text-
+func (jira) Strikethrough(text string) string {
+	return wrap([2]string{"-", "-"}, text)
+}
+
+// Code return the monospaced text, its marks escaped as monospace is still formatted
+func (j jira) Code(code string) string {
+	if code = strings.ReplaceAll(code, "\n", " "); strings.TrimSpace(code) == "" {
+		return ""
+	}
+	return "{{" + j.Escape(code) + "}}"
+}
+
+// CodeBlock return the code macro, the noformat macro without language
+func (jira) CodeBlock(code, lang string) string {
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	if lang == "" {
+		return "{noformat}\n" + code + "\n{noformat}"
+	}
+	return "{code:" + lang + "}\n" + code + "\n{code}"
+}
+
+// Quote return the quote macro
+func (jira) Quote(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "{quote}\n" + text + "\n{quote}"
+}
+
+// List return the list, an item marker repeats the markers of the lists it is nested in
+// so the lines of nested lists are prefixed with the marker of their item
+func (jira) List(l List) string {
+	marker := "*"
+	if l.Ordered {
+		marker = "#"
+	}
+	var lines []string
+	for _, item := range l.Items {
+		if len(item) == 0 {
+			lines = append(lines, marker+" ")
+		}
+		for i, chunk := range item {
+			switch {
+			case isJiraList(chunk):
+				for _, line := range strings.Split(chunk, "\n") {
+					if isJiraList(line) {
+						line = marker + line
+					}
+					lines = append(lines, line)
+				}
+			case i == 0:
+				lines = append(lines, marker+" "+chunk)
+			default:
+				lines = append(lines, chunk)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isJiraList report whether the block is a list
+func isJiraList(block string) bool {
+	marks := strings.TrimLeft(block, "*#")
+	return len(marks) < len(block) && strings.HasPrefix(marks, " ")
+}
+
+// Link return [text|href], [href] when the text is the url
+func (j jira) Link(text, href, title string) string {
+	href = strings.ReplaceAll(href, " ", "%20")
+	if text == "" || text == j.Escape(href) {
+		return "[" + href + "]"
+	}
+	return "[" + strings.ReplaceAll(text, "\n", " ") + "|" + href + "]"
+}
+
+// Image return !src! with the alt and title attributes
+func (jira) Image(alt, src, title string) string {
+	var attrs []string
+	if alt = strings.TrimSpace(whitespace.ReplaceAllString(alt, " ")); alt != "" {
+		attrs = append(attrs, "alt="+strings.NewReplacer(",", " ", "!", "", "|", "").Replace(alt))
+	}
+	if title != "" {
+		attrs = append(attrs, "title="+strings.NewReplacer(",", " ", "!", "", "|", "").Replace(title))
+	}
+	s := "!" + strings.ReplaceAll(src, " ", "%20")
+	if len(attrs) > 0 {
+		s += "|" + strings.Join(attrs, ",")
+	}
+	return s + "!"
+}
+
+// Table return the table rows, || separates header cells and | data cells,
+// spanned cells are padded with empty cells and blocks are joined by line breaks
+func (jira) Table(t Table) string {
+	var rows []string
+	if t.Caption != "" {
+		rows = append(rows, "*"+strings.ReplaceAll(t.Caption, "\n", " ")+"*")
+	}
+	for _, tr := range t.Rows {
+		var row string
+		last := "|"
+		for _, td := range tr {
+			sep := "|"
+			if td.Header {
+				sep = "||"
+			}
+			text := strings.Trim(td.Text, "\n")
+			text = strings.ReplaceAll(strings.ReplaceAll(text, "\n\n", " \\\\ "), "\n", " \\\\ ")
+			if text == "" {
+				text = " "
+			}
+			row += sep + text
+			for span := 1; span < td.Colspan; span++ {
+				row += sep + " "
+			}
+			last = sep
+		}
+		if row != "" {
+			rows = append(rows, row+last)
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+// Admonition return the info, tip, note or warning macro, note is the yellow
+// caution box of Jira so notes are info boxes
+func (jira) Admonition(kind, title, text string) string {
+	switch kind {
+	case "note":
+		kind = "info"
+	case "important":
+		kind = "note"
+	case "caution", "danger":
+		kind = "warning"
+	}
+	s := "{" + kind
+	if title != "" {
+		s += ":title=" + strings.NewReplacer("|", "", "}", "").Replace(title)
+	}
+	return s + "}\n" + text + "\n{" + kind + "}"
+}
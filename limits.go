@@ -0,0 +1,160 @@
+package h2md
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrInputTooLarge the html is larger than the input limit
+	ErrInputTooLarge = errors.New("h2md: input too large")
+	// ErrTooDeep the elements are nested deeper than the depth limit
+	ErrTooDeep = errors.New("h2md: nesting too deep")
+	// ErrTooManyNodes the tree has more nodes than the node limit
+	ErrTooManyNodes = errors.New("h2md: too many nodes")
+	// ErrOutputTooLarge the output is larger than the output limit
+	ErrOutputTooLarge = errors.New("h2md: output too large")
+)
+
+// Limits bounds of a conversion of untrusted html, 0 is no limit
+type Limits struct {
+	// MaxInputBytes largest html source, the tree of NewH2MDFromNode has no source
+	MaxInputBytes int
+	// MaxDepth deepest element nesting
+	MaxDepth int
+	// MaxNodes most nodes of the tree, elements, text and comments
+	MaxNodes int
+	// MaxOutputBytes largest output
+	MaxOutputBytes int
+}
+
+// DefaultLimits limits fit for untrusted pages
+var DefaultLimits = Limits{
+	MaxInputBytes:  10 << 20,
+	MaxDepth:       512,
+	MaxNodes:       1 << 20,
+	MaxOutputBytes: 20 << 20,
+}
+
+// interruptEvery nodes walked between checks of the context
+const interruptEvery = 256
+
+// interrupt stop the tree walk once its context is done or its output too large,
+// a nil interrupt never stops
+type interrupt struct {
+	ctx       context.Context
+	maxOutput int
+	nodes     int
+	err       error
+}
+
+// done report whether the walk should stop, checking the context every few nodes
+func (in *interrupt) done() bool {
+	if in == nil {
+		return false
+	}
+	if in.err == nil {
+		in.nodes++
+		if in.nodes%interruptEvery == 0 {
+			in.err = in.ctx.Err()
+		}
+	}
+	return in.err != nil
+}
+
+// written report whether the walk should stop, the output being written has the size,
+// so the walk aborts once a part of the output is beyond the limit
+func (in *interrupt) written(size int) bool {
+	if in == nil {
+		return false
+	}
+	if in.err == nil && in.maxOutput > 0 && size > in.maxOutput {
+		in.err = fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, in.maxOutput)
+	}
+	return in.err != nil
+}
+
+// SetLimits Bound the conversions of Convert and ConvertContext, Text ignores the limits
+func (h *Converter) SetLimits(l Limits) {
+	h.limits = l
+}
+
+// ConvertContext return the output with its warnings like Convert, the conversion aborts with
+// a limit error or the error of the context once it is done
+func (h *H2MD) ConvertContext(ctx context.Context) (string, []Warning, error) {
+	if h.Node == nil {
+		return "", nil, ErrNilNode
+	}
+	c := h.converter()
+	if err := c.checkInput(h.size); err != nil {
+		return "", nil, err
+	}
+	return c.convert(ctx, h.Node, h.source)
+}
+
+// checkInput return ErrInputTooLarge when the html of size bytes is beyond the input limit
+func (h *Converter) checkInput(size int) error {
+	if max := h.limits.MaxInputBytes; max > 0 && size > max {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrInputTooLarge, size, max)
+	}
+	return nil
+}
+
+// convert return the output of the tree parsed from the source with its warnings,
+// within the limits, the source may be "" when unknown
+func (h *Converter) convert(ctx context.Context, root *html.Node, source string) (string, []Warning, error) {
+	if err := checkTree(root, h.limits); err != nil {
+		return "", nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	w := &warner{root: root, source: source}
+	in := &interrupt{ctx: ctx, maxOutput: h.limits.MaxOutputBytes}
+	text := h.render(root, in, w)
+	if in.err == nil {
+		in.err = ctx.Err()
+	}
+	if in.err != nil {
+		return "", w.warnings, in.err
+	}
+	if max := h.limits.MaxOutputBytes; max > 0 && len(text) > max {
+		return "", w.warnings, fmt.Errorf("%w: %d bytes, the limit is %d", ErrOutputTooLarge, len(text), max)
+	}
+	return text, w.warnings, nil
+}
+
+// checkTree check the depth and node count of the tree against the limits,
+// without recursion so a deep tree can't exhaust the stack
+func checkTree(root *html.Node, l Limits) error {
+	if l.MaxDepth <= 0 && l.MaxNodes <= 0 {
+		return nil
+	}
+	nodes, depth := 0, 0
+	for n := root; n != nil; {
+		nodes++
+		if l.MaxNodes > 0 && nodes > l.MaxNodes {
+			return fmt.Errorf("%w: more than %d", ErrTooManyNodes, l.MaxNodes)
+		}
+		if n.FirstChild != nil {
+			n = n.FirstChild
+			depth++
+			if l.MaxDepth > 0 && depth > l.MaxDepth {
+				return fmt.Errorf("%w: more than %d levels", ErrTooDeep, l.MaxDepth)
+			}
+			continue
+		}
+		for n != root && n.NextSibling == nil {
+			n = n.Parent
+			depth--
+		}
+		if n == root {
+			break
+		}
+		n = n.NextSibling
+	}
+	return nil
+}
@@ -0,0 +1,544 @@
+package h2md
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/width"
+)
+
+// markdown renderer of the markdown flavor
+type markdown struct {
+	h *Converter
+	f *Flavor
+}
+
+// Escape escape the markdown characters of the text
+func (m *markdown) Escape(text string) string {
+	var b strings.Builder
+	rs := []rune(text)
+	at := func(i int) rune {
+		if i >= 0 && i < len(rs) {
+			return rs[i]
+		}
+		return 0
+	}
+	for i, r := range rs {
+		switch r {
+		case '\\', '*', '`', '[', ']', '<':
+			b.WriteByte('\\')
+		case '~':
+			if m.f.Strikethrough || m.f.Subscript {
+				b.WriteByte('\\')
+			}
+		case '^':
+			if m.f.Subscript {
+				b.WriteByte('\\')
+			}
+		case '_':
+			if !isAlnum(at(i-1)) || !isAlnum(at(i+1)) {
+				b.WriteByte('\\')
+			}
+		case '&':
+			if entity.MatchString(string(rs[i:])) {
+				b.WriteByte('\\')
+			}
+		case '$':
+			if m.f.Math {
+				b.WriteByte('\\')
+			}
+		case ':':
+			if m.f.Autolinks && at(i+1) == '/' && at(i+2) == '/' {
+				b.WriteByte('\\')
+			}
+		case '.':
+			if m.f.Autolinks && i >= 3 && strings.EqualFold(string(rs[i-3:i]), "www") && !isAlnum(at(i-4)) {
+				b.WriteByte('\\')
+			}
+		case '!':
+			// the text before a link would start an image
+			if i == len(rs)-1 {
+				b.WriteByte('\\')
+			}
+		case '@':
+			if m.f.Autolinks && isAlnum(at(i-1)) && isAlnum(at(i+1)) {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Paragraph return the inline markdown as a paragraph,
+// collapsing whitespace and escaping what would start a block
+func (m *markdown) Paragraph(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Trim(line, " ")
+		if line == "" || line == "\\" {
+			continue
+		}
+		if m.f.FencedDivs && strings.HasPrefix(line, ":::") {
+			line = "\\" + line
+		}
+		lines = append(lines, escapeLineStart(line))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	last := lines[len(lines)-1]
+	if strings.HasSuffix(last, "\\") && !strings.HasSuffix(last, "\\\\") {
+		lines[len(lines)-1] = strings.TrimRight(last[:len(last)-1], " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeLineStart escape the text that would start a block at the line start
+func escapeLineStart(line string) string {
+	switch line[0] {
+	case '#', '>', '-', '+', '=', '|':
+		return "\\" + line
+	}
+	if m := orderedMarker.FindStringSubmatch(line); m != nil {
+		return m[1] + "\\" + line[len(m[1]):]
+	}
+	return line
+}
+
+// Heading return the ATX heading
+func (m *markdown) Heading(level int, text, id string, classes []string) string {
+	if strings.HasSuffix(text, "#") {
+		text = text[:len(text)-1] + "\\#"
+	}
+	marker := strings.Repeat("#", level)
+	if text == "" {
+		return marker
+	}
+	if m.f.Attributes {
+		if a := attributeList(id, classes); a != "" {
+			if strings.HasSuffix(text, "}") {
+				text = text[:len(text)-1] + "\\}"
+			}
+			text += " " + a
+		}
+	}
+	return marker + " " + text
+}
+
+// ThematicBreak return the thematic break
+func (m *markdown) ThematicBreak() string {
+	return "---"
+}
+
+// LineBreak return the backslash hard line break
+func (m *markdown) LineBreak() string {
+	return "\\\n"
+}
+
+// Emphasis return the emphasis, ***a*** is em > strong so only a nested em needs the other delimiter
+func (m *markdown) Emphasis(text string) string {
+	if t := strings.Trim(text, " "); strings.HasPrefix(t, "**") || strings.HasSuffix(t, "**") {
+		return emphasis("*", "*", text)
+	}
+	return emphasis("*", "_", text)
+}
+
+// Strong return the strong emphasis
+func (m *markdown) Strong(text string) string {
+	return emphasis("**", "__", text)
+}
+
+// Strikethrough return ~~text~~, raw html or the text when the flavor has no strikethrough
+func (m *markdown) Strikethrough(text string) string {
+	if m.f.Strikethrough {
+		return emphasis("~~", "~~", text)
+	}
+	if m.f.RawHTML {
+		return "<del>" + text + "</del>"
+	}
+	return text
+}
+
+// Code return the code span
+func (m *markdown) Code(code string) string {
+	return codeSpan(code)
+}
+
+// emphasis wrap the text in the delimiter, moving the surrounding spaces out,
+// the alternative delimiter is used next to a nested emphasis
+func emphasis(delim, alt, text string) string {
+	trimmed := strings.Trim(text, " ")
+	if trimmed == "" {
+		return text
+	}
+	if strings.HasPrefix(trimmed, delim[:1]) || strings.HasSuffix(trimmed, delim[:1]) {
+		delim = alt
+	}
+	var s string
+	if strings.HasPrefix(text, " ") {
+		s = " "
+	}
+	s += delim + trimmed + delim
+	if strings.HasSuffix(text, " ") {
+		s += " "
+	}
+	return s
+}
+
+// codeSpan return the code wrapped in enough backticks
+func codeSpan(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if code == "" {
+		return ""
+	}
+	fence := "`"
+	for _, run := range backticks.FindAllString(code, -1) {
+		if len(run) >= len(fence) {
+			fence = strings.Repeat("`", len(run)+1)
+		}
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") ||
+		strings.HasPrefix(code, " ") && strings.HasSuffix(code, " ") && strings.Trim(code, " ") != "" {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+// script return the subscript or superscript, its spaces escaped
+func script(delim, text string) string {
+	if text = strings.TrimSpace(text); text == "" {
+		return ""
+	}
+	return delim + strings.ReplaceAll(text, " ", "\\ ") + delim
+}
+
+// attributes return the {#id .class} attributes of the element, or ""
+func attributes(n *html.Node) string {
+	return attributeList(strings.TrimSpace(attr(n, "id")), strings.Fields(attr(n, "class")))
+}
+
+// attributeList return the {#id .class} attributes, or ""
+func attributeList(id string, classes []string) string {
+	var attrs []string
+	if id != "" {
+		attrs = append(attrs, "#"+id)
+	}
+	for _, class := range classes {
+		attrs = append(attrs, "."+class)
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(attrs, " ") + "}"
+}
+
+// destination escape the url of a link or image
+func destination(url string) string {
+	r := strings.NewReplacer(" ", "%20", "<", "%3C", ">", "%3E", "\\", "\\\\", "(", "\\(", ")", "\\)", "\n", "")
+	return r.Replace(url)
+}
+
+// linkTitle return the quoted link title
+func linkTitle(title string) string {
+	if title == "" {
+		return ""
+	}
+	return " \"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(title) + "\""
+}
+
+// Link return the markdown link, or an autolink when the text is the url
+func (m *markdown) Link(text, href, title string) string {
+	if title == "" && text != "" && !strings.ContainsAny(href, " <>") {
+		if strings.Contains(href, ":") && text == m.Escape(href) {
+			return "<" + href + ">"
+		}
+		if strings.HasPrefix(href, "mailto:") && text == m.Escape(href[len("mailto:"):]) {
+			return "<" + href[len("mailto:"):] + ">"
+		}
+	}
+	return "[" + text + "](" + destination(href) + linkTitle(title) + ")"
+}
+
+// Image return the markdown image
+func (m *markdown) Image(alt, src, title string) string {
+	alt = strings.NewReplacer("\\", "\\\\", "[", "\\[", "]", "\\]", "\n", " ").Replace(alt)
+	return "![" + alt + "](" + destination(src) + linkTitle(title) + ")"
+}
+
+// CodeBlock return the fenced code block
+func (m *markdown) CodeBlock(code, lang string) string {
+	fence := "```"
+	for _, run := range backticks.FindAllString(code, -1) {
+		if len(run) >= len(fence) {
+			fence = strings.Repeat("`", len(run)+1)
+		}
+	}
+	if code == "" {
+		return fence + lang + "\n" + fence
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// Quote prefix the lines as a blockquote
+func (m *markdown) Quote(text string) string {
+	return m.quoteBlocks([]quotedBlock{{depth: 1, sep: 1, text: text}}, nil)
+}
+
+// quoteBlocks prefix the lines of the blocks by a > per level, blank lines of the
+// blockquote separating them, "" once the walk is interrupted
+func (m *markdown) quoteBlocks(blocks []quotedBlock, in *interrupt) string {
+	var b strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			b.WriteString("\n" + strings.TrimRight(strings.Repeat("> ", block.sep), " ") + "\n")
+		}
+		prefix := strings.Repeat("> ", block.depth)
+		blank := strings.TrimRight(prefix, " ")
+		for j, line := range strings.Split(block.text, "\n") {
+			if in.done() || in.written(b.Len()) {
+				return ""
+			}
+			if j > 0 {
+				b.WriteByte('\n')
+			}
+			if line == "" {
+				b.WriteString(blank)
+			} else {
+				b.WriteString(prefix)
+				b.WriteString(line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// listItem prefix the content with the list marker, indenting the following lines
+func listItem(marker, content string) string {
+	if content == "" {
+		return marker
+	}
+	// "- ---" is a thematic break, not a list item holding one
+	if content == "---" || strings.HasPrefix(content, "---\n") {
+		content = "***" + content[3:]
+	}
+	pad := strings.Repeat(" ", len(marker)+1)
+	lines := strings.Split(content, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = pad + lines[i]
+		}
+	}
+	return marker + " " + strings.Join(lines, "\n")
+}
+
+// List return the markdown list
+func (m *markdown) List(l List) string {
+	bullet, delim := "-", "."
+	// adjacent lists of the same kind need another marker to stay apart
+	if l.Adjacent {
+		bullet, delim = "*", ")"
+	}
+	var items []string
+	for i, item := range l.Items {
+		marker := bullet
+		if l.Ordered {
+			marker = strconv.Itoa(l.Start+i) + delim
+		}
+		items = append(items, listItem(marker, joinBlocks(item, l.Tight)))
+	}
+	if l.Tight {
+		return strings.Join(items, "\n")
+	}
+	return strings.Join(items, "\n\n")
+}
+
+// Admonition return the callout in the chosen style
+func (m *markdown) Admonition(kind, title, text string) string {
+	return m.h.admonition(kind, title, text)
+}
+
+// alignMarker return the delimiter row cell of the alignment
+func alignMarker(align string) string {
+	switch align {
+	case "left":
+		return ":---"
+	case "center":
+		return ":---:"
+	case "right":
+		return "---:"
+	}
+	return "---"
+}
+
+// cell return the cell content on a single line
+func (m *markdown) cell(text string) string {
+	br := " "
+	if m.f.RawHTML {
+		br = "<br>"
+	}
+	var parts []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.ReplaceAll(p, "\\\n", br); p != "" {
+			parts = append(parts, strings.ReplaceAll(p, "\n", " "))
+		}
+	}
+	return strings.ReplaceAll(strings.Join(parts, br), "|", "\\|")
+}
+
+// Table return the pipe table, or raw html when the flavor has no tables,
+// without raw html either the pipe table is left as plain text
+func (m *markdown) Table(t Table) string {
+	rows := tableRows(t.Node)
+	if m.f.GridTables && isComplexTable(rows) && !hasSpannedCells(rows) {
+		return caption(t) + gridTable(t.Rows, true)
+	}
+	if !m.f.Tables || isComplexTable(rows) {
+		if m.f.RawHTML {
+			return rawHTML(t.Node)
+		}
+	}
+	var cells [][]string
+	var aligns []string
+	var columns int
+	for i, tr := range t.Rows {
+		var row []string
+		for _, td := range tr {
+			row = append(row, m.cell(td.Text))
+			if i == 0 {
+				aligns = append(aligns, alignMarker(td.Align))
+			}
+			for span := td.Colspan; span > 1; span-- {
+				row = append(row, "")
+				if i == 0 {
+					aligns = append(aligns, "---")
+				}
+			}
+		}
+		if len(row) > columns {
+			columns = len(row)
+		}
+		cells = append(cells, row)
+	}
+	if columns == 0 {
+		return ""
+	}
+	for len(aligns) < columns {
+		aligns = append(aligns, "---")
+	}
+	line := func(row []string) string {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		return "| " + strings.Join(row, " | ") + " |"
+	}
+	lines := []string{line(cells[0]), line(aligns)}
+	for _, row := range cells[1:] {
+		lines = append(lines, line(row))
+	}
+	return caption(t) + strings.Join(lines, "\n")
+}
+
+// caption return the caption paragraph of the table followed by a blank line, or ""
+func caption(t Table) string {
+	if t.Caption == "" {
+		return ""
+	}
+	return t.Caption + "\n\n"
+}
+
+// gridTable return the grid table, its cells keep their block content,
+// spanned cells are padded with empty cells
+func gridTable(rows [][]TableCell, aligned bool) string {
+	var cells [][][]string
+	var aligns []string
+	var widths []int
+	header := len(rows) > 1
+	for i, tr := range rows {
+		var row [][]string
+		for _, td := range tr {
+			if i == 0 {
+				aligns = append(aligns, td.Align)
+				header = header && td.Header
+			}
+			lines := strings.Split(td.Text, "\n")
+			for span := 0; span < td.Colspan || span == 0; span++ {
+				if len(widths) <= len(row) {
+					widths = append(widths, 3)
+				}
+				for _, line := range lines {
+					if w := displayWidth(line); w > widths[len(row)] {
+						widths[len(row)] = w
+					}
+				}
+				row = append(row, lines)
+				lines = nil
+			}
+		}
+		cells = append(cells, row)
+	}
+	if len(widths) == 0 {
+		return ""
+	}
+	border := func(fill string, marked bool) string {
+		s := "+"
+		for i, w := range widths {
+			line := strings.Repeat(fill, w+2)
+			if aligned && marked && i < len(aligns) {
+				if aligns[i] == "left" || aligns[i] == "center" {
+					line = ":" + line[1:]
+				}
+				if aligns[i] == "right" || aligns[i] == "center" {
+					line = line[:len(line)-1] + ":"
+				}
+			}
+			s += line + "+"
+		}
+		return s
+	}
+	// without a header the alignments go in the top border
+	lines := []string{border("-", !header)}
+	for i, row := range cells {
+		height := 1
+		for _, cell := range row {
+			if len(cell) > height {
+				height = len(cell)
+			}
+		}
+		for l := 0; l < height; l++ {
+			s := "|"
+			for j, w := range widths {
+				var text string
+				if j < len(row) && l < len(row[j]) {
+					text = row[j][l]
+				}
+				s += " " + text + strings.Repeat(" ", w-displayWidth(text)) + " |"
+			}
+			lines = append(lines, s)
+		}
+		if i == 0 && header {
+			lines = append(lines, border("=", true))
+		} else {
+			lines = append(lines, border("-", false))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// displayWidth return the columns the text takes in a monospace font,
+// wide east asian characters take two and combining marks none
+func displayWidth(text string) int {
+	var n int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		case width.LookupRune(r).Kind() == width.EastAsianWide, width.LookupRune(r).Kind() == width.EastAsianFullwidth:
+			n += 2
+		default:
+			n++
+		}
+	}
+	return n
+}
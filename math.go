@@ -0,0 +1,264 @@
+package h2md
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// mathSymbols unicode characters of MathML tokens and their TeX commands
+var mathSymbols = map[string]string{
+	"α": `\alpha`, "β": `\beta`, "γ": `\gamma`, "δ": `\delta`, "ε": `\epsilon`, "ζ": `\zeta`,
+	"η": `\eta`, "θ": `\theta`, "ι": `\iota`, "κ": `\kappa`, "λ": `\lambda`, "μ": `\mu`,
+	"ν": `\nu`, "ξ": `\xi`, "π": `\pi`, "ρ": `\rho`, "σ": `\sigma`, "τ": `\tau`,
+	"υ": `\upsilon`, "φ": `\phi`, "χ": `\chi`, "ψ": `\psi`, "ω": `\omega`,
+	"Γ": `\Gamma`, "Δ": `\Delta`, "Θ": `\Theta`, "Λ": `\Lambda`, "Ξ": `\Xi`, "Π": `\Pi`,
+	"Σ": `\Sigma`, "Φ": `\Phi`, "Ψ": `\Psi`, "Ω": `\Omega`,
+	"∑": `\sum`, "∏": `\prod`, "∫": `\int`, "∮": `\oint`, "∂": `\partial`, "∇": `\nabla`,
+	"∞": `\infty`, "±": `\pm`, "∓": `\mp`, "×": `\times`, "÷": `\div`, "·": `\cdot`, "⋅": `\cdot`,
+	"≤": `\le`, "≥": `\ge`, "≠": `\ne`, "≈": `\approx`, "≡": `\equiv`, "∼": `\sim`,
+	"→": `\to`, "←": `\leftarrow`, "⇒": `\Rightarrow`, "⇔": `\Leftrightarrow`, "↦": `\mapsto`,
+	"∈": `\in`, "∉": `\notin`, "⊂": `\subset`, "⊆": `\subseteq`, "∪": `\cup`, "∩": `\cap`,
+	"∀": `\forall`, "∃": `\exists`, "¬": `\neg`, "∧": `\land`, "∨": `\lor`, "∅": `\emptyset`,
+	"…": `\ldots`, "⋯": `\cdots`, "−": "-", "{": `\{`, "}": `\}`,
+	// invisible function application, times and separator
+	"⁡": "", "⁢": "", "⁣": "",
+}
+
+// mathFunctions multi-letter identifiers written as TeX operators
+var mathFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"log": true, "ln": true, "lg": true, "exp": true, "lim": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "deg": true, "dim": true,
+}
+
+// mathAccents mover accents and their TeX commands
+var mathAccents = map[string]string{
+	"^": `\hat`, "ˆ": `\hat`, "¯": `\overline`, "‾": `\overline`, "→": `\vec`,
+	"~": `\tilde`, "˜": `\tilde`, "˙": `\dot`, "¨": `\ddot`,
+}
+
+// mathLargeOps operators taking limits as sub and superscript
+var mathLargeOps = map[string]bool{
+	`\sum`: true, `\prod`: true, `\int`: true, `\oint`: true, `\lim`: true, `\max`: true, `\min`: true,
+}
+
+var (
+	texCommand    = regexp.MustCompile(`^\\[a-zA-Z]+$`)
+	texCommandEnd = regexp.MustCompile(`\\[a-zA-Z]+$`)
+)
+
+// isMathJaxOutput report whether the element is a rendered MathJax 2 output,
+// its source is kept in the following script element
+func isMathJaxOutput(n *html.Node) bool {
+	if n.Data == "mjx-container" {
+		return false
+	}
+	for _, class := range []string{"MathJax_Preview", "MathJax", "MathJax_Display", "MathJax_SVG", "MathJax_SVG_Display", "MathJax_CHTML"} {
+		if hasClass(n, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// textContent return the text of the node and its descendants
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var s strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.WriteString(textContent(c))
+	}
+	return s.String()
+}
+
+// findElement return the first descendant element accepted by match
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			return c
+		}
+		if e := findElement(c, match); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// texAnnotation return the TeX source embedded by KaTeX and MathML semantics
+func texAnnotation(n *html.Node) (string, bool) {
+	a := findElement(n, func(e *html.Node) bool {
+		return e.Data == "annotation" && attr(e, "encoding") == "application/x-tex"
+	})
+	if a == nil {
+		return "", false
+	}
+	return strings.TrimSpace(textContent(a)), true
+}
+
+// mathTeX return the TeX source of the math element,
+// translating the MathML when no source is embedded
+func mathTeX(n *html.Node) string {
+	if tex, ok := texAnnotation(n); ok {
+		return tex
+	}
+	if n.Data != "math" {
+		if m := findElement(n, func(e *html.Node) bool { return e.Data == "math" }); m != nil {
+			n = m
+		}
+	}
+	return strings.TrimSpace(mathML(n))
+}
+
+// texMath return the TeX wrapped as inline or display math
+func texMath(tex string, display bool) string {
+	if display {
+		return "\n\n$$\n" + tex + "\n$$\n"
+	}
+	return "$" + tex + "$"
+}
+
+// joinTeX concatenate TeX fragments, separating commands from following letters
+func joinTeX(parts ...string) string {
+	var s string
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		if texCommandEnd.MatchString(s) && isLetter(p[0]) {
+			s += " "
+		}
+		s += p
+	}
+	return s
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// texGroup wrap the TeX in braces unless it is a single token
+func texGroup(tex string) string {
+	if len([]rune(tex)) == 1 || texCommand.MatchString(tex) {
+		return tex
+	}
+	return "{" + tex + "}"
+}
+
+// mathToken translate the text of a token element
+func mathToken(text string) string {
+	text = strings.TrimSpace(text)
+	if tex, ok := mathSymbols[text]; ok {
+		return tex
+	}
+	if mathFunctions[text] {
+		return `\` + text
+	}
+	var parts []string
+	for _, r := range text {
+		if tex, ok := mathSymbols[string(r)]; ok {
+			parts = append(parts, tex)
+		} else {
+			parts = append(parts, string(r))
+		}
+	}
+	return joinTeX(parts...)
+}
+
+// mathML translate common MathML elements to TeX
+func mathML(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return strings.TrimSpace(n.Data)
+	}
+	var args []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			args = append(args, mathML(c))
+		}
+	}
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	switch n.Data {
+	case "mi":
+		text := strings.TrimSpace(textContent(n))
+		if len([]rune(text)) > 1 && !mathFunctions[text] {
+			return `\mathrm{` + text + `}`
+		}
+		return mathToken(text)
+	case "mn", "mo":
+		return mathToken(textContent(n))
+	case "mtext":
+		return `\text{` + textContent(n) + `}`
+	case "mspace":
+		return `\ `
+	case "msup":
+		return joinTeX(texGroup(arg(0)), "^", texGroup(arg(1)))
+	case "msub":
+		return joinTeX(texGroup(arg(0)), "_", texGroup(arg(1)))
+	case "msubsup":
+		return joinTeX(texGroup(arg(0)), "_", texGroup(arg(1)), "^", texGroup(arg(2)))
+	case "mfrac":
+		return `\frac{` + arg(0) + `}{` + arg(1) + `}`
+	case "msqrt":
+		return `\sqrt{` + joinTeX(args...) + `}`
+	case "mroot":
+		return `\sqrt[` + arg(1) + `]{` + arg(0) + `}`
+	case "mover":
+		if accent, ok := mathAccents[strings.TrimSpace(textContent(lastElement(n)))]; ok && len(args) == 2 {
+			return accent + `{` + arg(0) + `}`
+		}
+		if mathLargeOps[arg(0)] {
+			return joinTeX(arg(0), "^", texGroup(arg(1)))
+		}
+		return `\overset{` + arg(1) + `}{` + arg(0) + `}`
+	case "munder":
+		if mathLargeOps[arg(0)] {
+			return joinTeX(arg(0), "_", texGroup(arg(1)))
+		}
+		return `\underset{` + arg(1) + `}{` + arg(0) + `}`
+	case "munderover":
+		return joinTeX(arg(0), "_", texGroup(arg(1)), "^", texGroup(arg(2)))
+	case "mfenced":
+		open, close := "(", ")"
+		if v, ok := attrOk(n, "open"); ok {
+			open = v
+		}
+		if v, ok := attrOk(n, "close"); ok {
+			close = v
+		}
+		sep := ","
+		if v, ok := attrOk(n, "separators"); ok {
+			sep = v
+		}
+		return joinTeX(mathToken(open), strings.Join(args, sep), mathToken(close))
+	case "mtable":
+		return `\begin{matrix}` + strings.Join(args, ` \\ `) + `\end{matrix}`
+	case "mtr", "mlabeledtr":
+		return strings.Join(args, " & ")
+	case "mphantom":
+		return `\phantom{` + joinTeX(args...) + `}`
+	case "semantics":
+		return arg(0)
+	case "annotation", "annotation-xml":
+		return ""
+	}
+	return joinTeX(args...)
+}
+
+// lastElement return the last child element
+func lastElement(n *html.Node) *html.Node {
+	for c := n.LastChild; c != nil; c = c.PrevSibling {
+		if c.Type == html.ElementNode {
+			return c
+		}
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package h2md
+
+import (
+	"golang.org/x/net/html"
+)
+
+// Renderer write the constructs of a markup language, the tree walk hands it
+// the rendered content of the children. Markdown is written by the renderer of
+// the flavor, AsciiDoc and RST are the other renderers
+type Renderer interface {
+	// Escape escape the markup characters of the text
+	Escape(text string) string
+	// Paragraph return the inline content as a paragraph, "" when it is empty
+	Paragraph(text string) string
+	// Heading return the heading of level 1 to 6
+	Heading(level int, text, id string, classes []string) string
+	// ThematicBreak return the horizontal rule
+	ThematicBreak() string
+	// LineBreak return the hard line break inside a paragraph
+	LineBreak() string
+	// Emphasis return the emphasized text
+	Emphasis(text string) string
+	// Strong return the strongly emphasized text
+	Strong(text string) string
+	// Strikethrough return the deleted text
+	Strikethrough(text string) string
+	// Code return the code span of the raw code
+	Code(code string) string
+	// CodeBlock return the code block of the raw code, lang may be ""
+	CodeBlock(code, lang string) string
+	// Quote return the block quote of the rendered blocks
+	Quote(text string) string
+	// List return the list
+	List(l List) string
+	// Link return the link of the rendered text
+	Link(text, href, title string) string
+	// Image return the image
+	Image(alt, src, title string) string
+	// Table return the table
+	Table(t Table) string
+	// Admonition return the note, tip or warning box of the rendered blocks
+	Admonition(kind, title, text string) string
+}
+
+// List a list with its items rendered
+type List struct {
+	// Ordered numbered list
+	Ordered bool
+	// Start number of the first item
+	Start int
+	// Tight the items hold no paragraphs
+	Tight bool
+	// Depth number of lists the list is nested in
+	Depth int
+	// Adjacent the list follows a list of the same kind
+	Adjacent bool
+	// Items rendered blocks of each item
+	Items [][]string
+}
+
+// TableCell a table cell with its content rendered
+type TableCell struct {
+	// Text rendered blocks of the cell separated by blank lines
+	Text string
+	// Header th cell
+	Header bool
+	// Align left, center, right or ""
+	Align string
+	// Colspan columns spanned, at least 1
+	Colspan int
+	// Rowspan rows spanned, at least 1
+	Rowspan int
+}
+
+// Table a table with its cells rendered
+type Table struct {
+	// Caption rendered caption paragraph, may be ""
+	Caption string
+	// Rows cells of each row
+	Rows [][]TableCell
+	// Node table element, for renderers falling back to raw html
+	Node *html.Node
+}
+
+// SetRenderer Write the markup of the renderer instead of the default output, e.g. AsciiDoc or RST,
+// SetFlavor switches back to markdown
+func (h *Converter) SetRenderer(r Renderer) {
+	h.renderer = r
+}
@@ -0,0 +1,248 @@
+package h2md
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RST renderer writing reStructuredText for Sphinx and docutils
+var RST Renderer = rst{}
+
+var (
+	// rstBlockStart lines that would start a list, a field list or a line block
+	rstBlockStart = regexp.MustCompile(`^([-*+•] |#\. |\| |:\S|>>> )`)
+	// rstEnumerator numbered list items: 1. 1) (1) a.
+	rstEnumerator = regexp.MustCompile(`^\(?([0-9]+|[a-zA-Z])([.)]) `)
+)
+
+// rst renderer of reStructuredText
+type rst struct{}
+
+// Escape escape the characters that could start inline markup or end a reference
+func (rst) Escape(text string) string {
+	var b strings.Builder
+	rs := []rune(text)
+	at := func(i int) rune {
+		if i >= 0 && i < len(rs) {
+			return rs[i]
+		}
+		return 0
+	}
+	for i, r := range rs {
+		switch r {
+		case '\\':
+			b.WriteByte('\\')
+		case '*', '`', '|':
+			if !isAlnum(at(i-1)) && at(i+1) != ' ' && at(i+1) != 0 {
+				b.WriteByte('\\')
+			}
+		case '_':
+			if isAlnum(at(i-1)) && !isAlnum(at(i+1)) || at(i+1) == '`' {
+				b.WriteByte('\\')
+			}
+		case '.':
+			// ".. " starts a directive or a comment
+			if at(i+1) == '.' && at(i+2) == ' ' && at(i-1) != '.' {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Paragraph return the inline content as paragraphs, images are directives
+// so they come as their own blocks
+func (r rst) Paragraph(text string) string {
+	var blocks []string
+	for _, block := range strings.Split(text, "\n\n") {
+		if strings.HasPrefix(strings.TrimSpace(block), ".. image::") {
+			blocks = append(blocks, strings.TrimSpace(block))
+		} else if p := r.paragraph(block); p != "" {
+			blocks = append(blocks, p)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// paragraph return the lines as a paragraph, guarding the lines that would start a block
+func (rst) paragraph(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Trim(line, " ")
+		if line == "" {
+			continue
+		}
+		switch {
+		case rstBlockStart.MatchString(line), isAdornment(line):
+			line = "\\" + line
+		case rstEnumerator.MatchString(line):
+			m := rstEnumerator.FindStringSubmatchIndex(line)
+			line = line[:m[4]] + "\\" + line[m[4]:]
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	// a paragraph ending with :: introduces a literal block
+	last := lines[len(lines)-1]
+	if strings.HasSuffix(last, "::") {
+		lines[len(lines)-1] = last[:len(last)-1] + "\\:"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isAdornment report whether the line repeats a punctuation character,
+// read as a section title underline or a transition
+func isAdornment(line string) bool {
+	if len(line) < 4 || isAlnum(rune(line[0])) || line[0] == ' ' || line[0] > '~' {
+		return false
+	}
+	return strings.Trim(line, line[:1]) == ""
+}
+
+// rstAdornments underline characters of the heading levels
+var rstAdornments = []string{"=", "-", "~", "^", "\"", "'"}
+
+// Heading return the section title underlined by the character of the level,
+// the id becomes a reference label
+func (rst) Heading(level int, text, id string, classes []string) string {
+	if text == "" {
+		return ""
+	}
+	s := text + "\n" + strings.Repeat(rstAdornments[level-1], displayWidth(text))
+	if id != "" {
+		s = ".. _" + id + ":\n\n" + s
+	}
+	return s
+}
+
+// ThematicBreak return the transition
+func (rst) ThematicBreak() string {
+	return "----"
+}
+
+// LineBreak return a line break, paragraphs have no hard breaks
+func (rst) LineBreak() string {
+	return "\n"
+}
+
+// Emphasis return *text*
+func (rst) Emphasis(text string) string {
+	return emphasis("*", "*", text)
+}
+
+// Strong return **text**
+func (rst) Strong(text string) string {
+	return emphasis("**", "**", text)
+}
+
+// Strikethrough return the text, RST has no strikethrough
+func (rst) Strikethrough(text string) string {
+	return text
+}
+
+// Code return the inline literal
+func (rst) Code(code string) string {
+	if code = strings.TrimSpace(strings.ReplaceAll(code, "\n", " ")); code == "" {
+		return ""
+	}
+	return "``" + code + "``"
+}
+
+// CodeBlock return the code-block directive, a literal block without language
+// and the math directive for math
+func (rst) CodeBlock(code, lang string) string {
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	var s string
+	switch lang {
+	case "":
+		s = "::"
+	case "math":
+		s = ".. math::"
+	default:
+		s = ".. code-block:: " + lang
+	}
+	return s + "\n\n" + rstIndent(code, "   ")
+}
+
+// rstIndent indent every line
+func rstIndent(text, prefix string) string {
+	return prefix + indent(text, prefix)
+}
+
+// Quote return the indented block quote, after an empty comment so it is not
+// read as the content of a preceding directive or list
+func (rst) Quote(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "..\n\n" + rstIndent(text, "   ")
+}
+
+// List return the list, the blocks of an item are indented under its marker
+func (rst) List(l List) string {
+	var items []string
+	sep := "\n"
+	for i, item := range l.Items {
+		marker := "-"
+		if l.Ordered {
+			marker = strconv.Itoa(l.Start+i) + "."
+		}
+		if len(item) > 1 || !l.Tight {
+			sep = "\n\n"
+		}
+		content := strings.Join(item, "\n\n")
+		items = append(items, strings.TrimRight(marker+" "+indent(content, strings.Repeat(" ", len(marker)+1)), " "))
+	}
+	return strings.Join(items, sep)
+}
+
+// Link return the anonymous hyperlink reference, a bare url when the text is the url,
+// linked images get the target option
+func (r rst) Link(text, href, title string) string {
+	if img := strings.TrimSpace(text); strings.HasPrefix(img, ".. image::") && !strings.Contains(img, "\n\n") {
+		return "\n\n" + img + "\n   :target: " + href + "\n\n"
+	}
+	absolute := strings.Contains(href, "://") || strings.HasPrefix(href, "mailto:")
+	if absolute && (text == "" || text == r.Escape(href)) {
+		return href
+	}
+	if text == "" {
+		text = r.Escape(href)
+	}
+	return "`" + strings.ReplaceAll(text, "<", "\\<") + " <" + strings.ReplaceAll(href, " ", "%20") + ">`__"
+}
+
+// Image return the image directive as its own block
+func (rst) Image(alt, src, title string) string {
+	s := "\n\n.. image:: " + strings.ReplaceAll(src, " ", "%20")
+	if alt = strings.TrimSpace(whitespace.ReplaceAllString(alt, " ")); alt != "" {
+		s += "\n   :alt: " + alt
+	}
+	return s + "\n\n"
+}
+
+// Table return the grid table, spanned cells are padded with empty cells
+func (rst) Table(t Table) string {
+	table := gridTable(t.Rows, false)
+	if table == "" || t.Caption == "" {
+		return table
+	}
+	return ".. table:: " + strings.ReplaceAll(t.Caption, "\n", " ") + "\n\n" + rstIndent(table, "   ")
+}
+
+// Admonition return the admonition directive, titled ones use the generic admonition
+func (rst) Admonition(kind, title, text string) string {
+	if kind == "info" {
+		kind = "note"
+	}
+	if title != "" {
+		return ".. admonition:: " + title + "\n   :class: " + kind + "\n\n" + rstIndent(text, "   ")
+	}
+	return ".. " + kind + "::\n\n" + rstIndent(text, "   ")
+}
@@ -0,0 +1,147 @@
+package h2md
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// specExample an example of the CommonMark and GFM specs
+type specExample struct {
+	Example  int    `json:"example"`
+	Section  string `json:"section"`
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+}
+
+func loadSpec(t *testing.T, name string) []specExample {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var examples []specExample
+	if err := json.Unmarshal(data, &examples); err != nil {
+		t.Fatal(err)
+	}
+	return examples
+}
+
+var specBlockTags = regexp.MustCompile(`\s*(</?(p|li|ul|ol|blockquote|h[1-6]|hr|pre|table|thead|tbody|tr|th|td|dl|dt|dd|div|br)( [^>]*)?>)\s*`)
+
+// normalizeHTML render the html with sorted attributes and collapsed whitespace
+func normalizeHTML(s string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return s
+	}
+	var b strings.Builder
+	var f func(n *html.Node, pre bool)
+	f = func(n *html.Node, pre bool) {
+		switch n.Type {
+		case html.TextNode:
+			text := html.EscapeString(n.Data)
+			if !pre {
+				text = whitespace.ReplaceAllString(text, " ")
+			}
+			b.WriteString(text)
+		case html.ElementNode:
+			attrs := make([]string, 0, len(n.Attr))
+			for _, a := range n.Attr {
+				if a.Key == "alt" && a.Val == "" {
+					continue
+				}
+				attrs = append(attrs, a.Key+`="`+html.EscapeString(a.Val)+`"`)
+			}
+			sort.Strings(attrs)
+			b.WriteString("<" + strings.TrimSpace(n.Data+" "+strings.Join(attrs, " ")) + ">")
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				f(c, pre || n.Data == "pre")
+			}
+			b.WriteString("</" + n.Data + ">")
+		}
+	}
+	for _, n := range nodes {
+		f(n, false)
+	}
+	return strings.TrimSpace(specBlockTags.ReplaceAllString(b.String(), "$1"))
+}
+
+// testSpec convert the html of the examples and check the markdown renders the same html
+func testSpec(t *testing.T, flavor Flavor, md goldmark.Markdown, examples []specExample, skip map[string]string) {
+	var passed int
+	for _, e := range examples {
+		if _, ok := skip[e.Section]; ok {
+			continue
+		}
+		if _, ok := skip[fmt.Sprintf("%s %d", e.Section, e.Example)]; ok {
+			continue
+		}
+		h, err := NewH2MD(e.HTML)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		h.SetFlavor(flavor)
+		text := h.Text()
+		var out bytes.Buffer
+		if err := md.Convert([]byte(text), &out); err != nil {
+			t.Error(err)
+			continue
+		}
+		if expect, got := normalizeHTML(e.HTML), normalizeHTML(out.String()); expect != got {
+			t.Errorf("%s example %d\nhtml:     %q\nmarkdown: %q\nexpect:   %q\ngot:      %q", e.Section, e.Example, e.HTML, text, expect, got)
+			continue
+		}
+		passed++
+	}
+	t.Logf("%d examples passed", passed)
+}
+
+// specSkips sections and examples whose html has no markdown equivalent to convert back to
+var specSkips = map[string]string{
+	"HTML blocks":          "raw html is converted to markdown",
+	"Raw HTML":             "raw html is converted to markdown",
+	"Backslash escapes 21": "unclosed raw html link",
+	"Entity and numeric character references 31": "unclosed raw html link",
+	"Code spans 344":                   "unclosed raw html link",
+	"Emphasis and strong emphasis 476": "unclosed raw html link",
+	"Emphasis and strong emphasis 477": "unclosed raw html link",
+	"Hard line breaks 642":             "unclosed raw html link",
+	"Hard line breaks 643":             "unclosed raw html link",
+	"Link reference definitions 201":   "unknown raw html element",
+	"Links 491":                        "unknown raw html element",
+	"Links 494":                        "unclosed raw html element",
+	"Links 524":                        "unknown raw html element",
+	"Links 536":                        "unknown raw html element",
+	"Emphasis and strong emphasis 417": "strong nested in strong has no delimiter left",
+}
+
+func TestGFMSpec(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+			extension.TaskList, extension.Strikethrough, extension.Linkify,
+		),
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+	examples := append(loadSpec(t, "testdata/spec.json"), loadSpec(t, "testdata/gfm_spec.json")...)
+	testSpec(t, GFM, md, examples, specSkips)
+}
+
+func TestCommonMarkSpec(t *testing.T) {
+	md := goldmark.New(goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()))
+	testSpec(t, CommonMark, md, loadSpec(t, "testdata/spec.json"), specSkips)
+	// extensions are written as raw html
+	testSpec(t, CommonMark, md, loadSpec(t, "testdata/gfm_spec.json"), specSkips)
+}
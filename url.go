@@ -0,0 +1,165 @@
+package h2md
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBodyTooLarge the fetched page is larger than the limit of the fetcher
+	ErrBodyTooLarge = errors.New("h2md: body too large")
+	// ErrTooManyRedirects the fetched url redirects more times than the limit of the fetcher
+	ErrTooManyRedirects = errors.New("h2md: too many redirects")
+)
+
+// Page a fetched html page
+type Page struct {
+	// URL final url after the redirects
+	URL *url.URL
+	// ContentType Content-Type header, its charset decodes the body
+	ContentType string
+	// Body raw body
+	Body []byte
+}
+
+// Fetcher fetch the page of a url
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*Page, error)
+}
+
+// HTTPFetcher fetcher of http and https urls, the zero value uses the defaults
+type HTTPFetcher struct {
+	// Client sends the requests, a client with the timeout when nil
+	Client *http.Client
+	// Timeout time limit of a fetch, 30 seconds when 0
+	Timeout time.Duration
+	// UserAgent User-Agent header, DefaultUserAgent when empty
+	UserAgent string
+	// MaxBytes largest body, 10 MiB when 0
+	MaxBytes int64
+	// MaxRedirects redirects followed, 10 when 0 and none when negative
+	MaxRedirects int
+}
+
+// DefaultUserAgent User-Agent header of the HTTPFetcher
+const DefaultUserAgent = "h2md (+https://github.com/diiyw/h2md)"
+
+// DefaultFetcher fetcher of NewH2MDFromURL without one
+var DefaultFetcher Fetcher = &HTTPFetcher{}
+
+// Fetch get the url, following the redirects up to the limit
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	timeout, maxBytes, maxRedirects := f.Timeout, f.MaxBytes, f.MaxRedirects
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	if maxBytes == 0 {
+		maxBytes = 10 << 20
+	}
+	if maxRedirects == 0 {
+		maxRedirects = 10
+	}
+	client := http.Client{Timeout: timeout}
+	if f.Client != nil {
+		client = *f.Client
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return ErrTooManyRedirects
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	ua := f.UserAgent
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrTooManyRedirects) {
+			return nil, ErrTooManyRedirects
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("h2md: fetch %s: %s", rawURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, ErrBodyTooLarge
+	}
+	return &Page{URL: resp.Request.URL, ContentType: resp.Header.Get("Content-Type"), Body: body}, nil
+}
+
+// URLOptions settings of NewH2MDFromURL
+type URLOptions struct {
+	// Fetcher fetch the page, DefaultFetcher when nil
+	Fetcher Fetcher
+	// Charset encoding of the page, it overrides the detected one
+	Charset string
+}
+
+// NewH2MDFromURL create H2MD with the page of the url, the body is decoded by the charset
+// of its Content-Type or as NewH2MDFromReader does, the relative links and images resolve against the final url
+func NewH2MDFromURL(ctx context.Context, rawURL string, opts *URLOptions) (*H2MD, error) {
+	fetcher := DefaultFetcher
+	if opts != nil && opts.Fetcher != nil {
+		fetcher = opts.Fetcher
+	}
+	page, err := fetcher.Fetch(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	ro := &ReaderOptions{ContentType: page.ContentType}
+	if opts != nil {
+		ro.Charset = opts.Charset
+	}
+	h, err := NewH2MDFromReader(bytes.NewReader(page.Body), ro)
+	if err != nil {
+		return nil, err
+	}
+	if page.URL != nil {
+		h.baseURL = page.URL
+	}
+	return h, nil
+}
+
+// SetBaseURL Resolve the relative links, images and embeds against the url
+func (h *Converter) SetBaseURL(base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	h.baseURL = u
+	return nil
+}
+
+// resolve return the url resolved against the base url, fragments and invalid urls are kept
+func (h *Converter) resolve(ref string) string {
+	if h.baseURL == nil || ref == "" || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ref
+	}
+	return h.baseURL.ResolveReference(u).String()
+}
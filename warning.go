@@ -0,0 +1,255 @@
+package h2md
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNilNode the converter has no html node
+var ErrNilNode = errors.New("h2md: nil html node")
+
+// WarningKind kind of content the conversion loses
+type WarningKind int
+
+const (
+	// WarnUnknownTag element without conversion, its tag is dropped and its content kept
+	WarnUnknownTag WarningKind = iota
+	// WarnTable table the output can't express, its cells are flattened or padded
+	WarnTable
+	// WarnImage image without a usable src, or with a relative src and no base url
+	WarnImage
+	// WarnDropped element dropped with its content, e.g. svg drawings or embeds without src
+	WarnDropped
+)
+
+// warningKinds names of the warning kinds indexed by kind
+var warningKinds = []string{"unknown-tag", "table", "image", "dropped"}
+
+// String return the name of the kind
+func (k WarningKind) String() string {
+	if k >= 0 && int(k) < len(warningKinds) {
+		return warningKinds[k]
+	}
+	return fmt.Sprintf("WarningKind(%d)", int(k))
+}
+
+// Position position in the html source, the zero value when unknown
+type Position struct {
+	// Line 1 based line of the start tag
+	Line int
+	// Column 1 based byte column of the start tag
+	Column int
+	// Offset 0 based byte offset of the start tag
+	Offset int
+}
+
+// Warning content lost by the conversion
+type Warning struct {
+	// Kind kind of the loss
+	Kind WarningKind
+	// Path element path from the root, e.g. /html/body/div[2]/table
+	Path string
+	// Pos position of the element in the html source, the zero value without source
+	Pos Position
+	// Message what is lost
+	Message string
+	// Node element the warning is about
+	Node *html.Node
+}
+
+// String return the warning as line:column path: message
+func (w Warning) String() string {
+	if w.Pos.Line == 0 {
+		return w.Path + ": " + w.Message
+	}
+	return fmt.Sprintf("%d:%d %s: %s", w.Pos.Line, w.Pos.Column, w.Path, w.Message)
+}
+
+// Convert return the output with the warnings about the content it loses,
+// the error reports a converter without node or a conversion beyond the limits
+func (h *H2MD) Convert() (string, []Warning, error) {
+	return h.ConvertContext(context.Background())
+}
+
+// warner collect the warnings of a conversion met by its tree walk,
+// a nil warner collects none
+type warner struct {
+	root      *html.Node
+	source    string
+	positions map[*html.Node]Position
+	// siblings 1 based indexes among the siblings of the same tag of the children
+	// of the parents met by the paths
+	siblings map[*html.Node]map[*html.Node]int
+	warnings []Warning
+}
+
+// warn add the warning about the element, positioned in the source the tree was parsed from
+func (w *warner) warn(n *html.Node, kind WarningKind, msg string) {
+	if w == nil || msg == "" {
+		return
+	}
+	if w.positions == nil && w.source != "" {
+		w.positions = sourcePositions(w.source, w.root)
+	}
+	w.warnings = append(w.warnings, Warning{Kind: kind, Path: w.path(n), Pos: w.positions[n], Message: msg, Node: n})
+}
+
+// unknown warn about the element without conversion, custom elements are left alone
+func (w *warner) unknown(n *html.Node) {
+	if n.Type == html.ElementNode && !blockTags[n.Data] && !inlineTags[n.Data] && !rawInlineTags[n.Data] && !strings.Contains(n.Data, "-") {
+		w.warn(n, WarnUnknownTag, "unknown element <"+n.Data+">, its content is kept")
+	}
+}
+
+// imageWarning return why the image is lost or unresolved, "" when it is fine
+func (h *Converter) imageWarning(n *html.Node) string {
+	if isTrackingPixel(n) {
+		return ""
+	}
+	src := h.imageSrc(n)
+	if src == "" {
+		return "image without src dropped"
+	}
+	u, err := url.Parse(src)
+	if err != nil {
+		return "invalid image src " + src
+	}
+	if !u.IsAbs() && u.Host == "" && h.baseURL == nil {
+		return "relative image src " + src + " without base url"
+	}
+	return ""
+}
+
+// tableWarning return how the output loses the table, "" when it keeps it
+func (h *Converter) tableWarning(n *html.Node) string {
+	rows := tableRows(n)
+	complex, spanned := isComplexTable(rows), hasSpannedCells(rows)
+	if h.renderer != nil {
+		if complex {
+			return "table cells hold blocks or span rows, the renderer may flatten them"
+		}
+		return ""
+	}
+	f := h.flavor
+	if f == nil {
+		f = &defaultFlavor
+	}
+	switch {
+	case f.GridTables && complex && !spanned:
+		return ""
+	case (!f.Tables || complex) && f.RawHTML:
+		return ""
+	case complex:
+		return "table cells hold blocks or span rows, the pipe table flattens them"
+	case !f.Tables:
+		return "the flavor has no tables and no raw html, the pipe table is not a table"
+	case spanned:
+		return "spanned cells are padded with empty cells"
+	}
+	return ""
+}
+
+// path return the path of the node from the root, elements with siblings
+// of the same tag get their 1 based index, the children of a parent are
+// counted once for all the warnings
+func (w *warner) path(n *html.Node) string {
+	var parts []string
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		part := n.Data
+		if n.Parent != nil {
+			if index := w.siblingIndexes(n.Parent)[n]; index > 0 {
+				part += fmt.Sprintf("[%d]", index)
+			}
+		}
+		parts = append(parts, part)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// siblingIndexes return the indexes of the element children of the parent
+// that share their tag with another child
+func (w *warner) siblingIndexes(parent *html.Node) map[*html.Node]int {
+	if indexes, ok := w.siblings[parent]; ok {
+		return indexes
+	}
+	counts := make(map[string]int)
+	for c := parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			counts[c.Data]++
+		}
+	}
+	indexes := make(map[*html.Node]int)
+	seen := make(map[string]int)
+	for c := parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && counts[c.Data] > 1 {
+			seen[c.Data]++
+			indexes[c] = seen[c.Data]
+		}
+	}
+	if w.siblings == nil {
+		w.siblings = make(map[*html.Node]map[*html.Node]int)
+	}
+	w.siblings[parent] = indexes
+	return indexes
+}
+
+// positionLookahead start tags searched for the next element, the parser adds
+// implied elements like tbody that have no tag in the source
+const positionLookahead = 4
+
+// sourcePositions return the positions of the elements of the tree parsed from the source,
+// matching them in document order with the start tags, implied elements have none
+func sourcePositions(src string, root *html.Node) map[*html.Node]Position {
+	type tag struct {
+		name string
+		pos  Position
+	}
+	var tags []tag
+	z := html.NewTokenizer(strings.NewReader(src))
+	offset, line, column := 0, 1, 1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			name, _ := z.TagName()
+			tags = append(tags, tag{string(name), Position{Line: line, Column: column, Offset: offset}})
+		}
+		offset += len(raw)
+		if i := strings.LastIndexByte(string(raw), '\n'); i >= 0 {
+			line += strings.Count(string(raw), "\n")
+			column = len(raw) - i
+		} else {
+			column += len(raw)
+		}
+	}
+	positions := make(map[*html.Node]Position)
+	next := 0
+	var f func(n *html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i := next; i < len(tags) && i < next+positionLookahead; i++ {
+				if tags[i].name == n.Data {
+					positions[n] = tags[i].pos
+					next = i + 1
+					break
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(root)
+	return positions
+}